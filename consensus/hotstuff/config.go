@@ -18,14 +18,75 @@
 
 package hotstuff
 
+import (
+	"fmt"
+	"strings"
+)
+
 type SelectProposerPolicy uint64
 
 const (
 	RoundRobin SelectProposerPolicy = iota
 	Sticky
 	VRF
+	WeightedRoundRobin
+	Fixed
+	HashSeeded
+	Shuffle
+)
+
+var policyNames = map[SelectProposerPolicy]string{
+	RoundRobin:         "roundRobin",
+	Sticky:             "sticky",
+	VRF:                "vrf",
+	WeightedRoundRobin: "weightedRoundRobin",
+	Fixed:              "fixed",
+	HashSeeded:         "hashSeeded",
+	Shuffle:            "shuffle",
+}
+
+// String returns the name of the policy as accepted by ParsePolicy.
+func (p SelectProposerPolicy) String() string {
+	if name, ok := policyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("SelectProposerPolicy(%d)", uint64(p))
+}
+
+// ParsePolicy returns the policy of the given name, e.g. "sticky". Names are
+// matched case insensitively and unknown names are rejected.
+func ParsePolicy(name string) (SelectProposerPolicy, error) {
+	for policy, policyName := range policyNames {
+		if strings.EqualFold(name, policyName) {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown proposer policy %q", name)
+}
+
+// FaultModel is the failure model a validator set tolerates, it determines
+// the fault bound and the quorum size.
+type FaultModel uint64
+
+const (
+	// BFT tolerates f = (n-1)/3 byzantine validators, quorums need ceil(2n/3),
+	// which is 2f+1 when n = 3f+1.
+	BFT FaultModel = iota
+	// CFT tolerates f = (n-1)/2 crashed validators, quorums need a majority
+	// n/2+1. It must only be used among trusted validators.
+	CFT
 )
 
+func (m FaultModel) String() string {
+	switch m {
+	case BFT:
+		return "bft"
+	case CFT:
+		return "cft"
+	}
+	return fmt.Sprintf("FaultModel(%d)", uint64(m))
+}
+
 type Config struct {
 	RequestTimeout uint64               `toml:",omitempty"` // The timeout for each Istanbul round in milliseconds.
 	BlockPeriod    uint64               `toml:",omitempty"` // Default minimum difference between two consecutive block's timestamps in second for basic hotstuff and mill-seconds for event-driven
@@ -58,7 +58,7 @@ func (c *core) handlePreCommitVote(data *hotstuff.Message, src hotstuff.Validato
 
 	logger.Trace("handlePreCommitVote", "src", src.Address(), "hash", vote.Digest)
 
-	if size := c.current.PreCommitVoteSize(); size >= c.Q() && c.currentState() < StatePreCommitted {
+	if size := c.current.PreCommitVoteSize(); c.valSet.QuorumReached(size) && c.currentState() < StatePreCommitted {
 		c.lockQCAndProposal(c.current.PrepareQC())
 		logger.Trace("acceptPreCommitted", "msg", msgTyp, "src", src.Address(), "hash", c.current.PreCommittedQC().Hash, "msgSize", size)
 		c.sendCommit()
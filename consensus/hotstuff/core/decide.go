@@ -59,7 +59,7 @@ func (c *core) handleCommitVote(data *hotstuff.Message, src hotstuff.Validator)
 
 	logger.Trace("handleCommitVote", "msg", msgTyp, "src", src.Address(), "hash", vote.Digest)
 
-	if size := c.current.CommitVoteSize(); size >= c.Q() && c.currentState() < StateCommitted {
+	if size := c.current.CommitVoteSize(); c.valSet.QuorumReached(size) && c.currentState() < StateCommitted {
 		c.current.SetState(StateCommitted)
 		c.current.SetCommittedQC(c.current.PreCommittedQC())
 		logger.Trace("acceptCommit", "msg", msgTyp, "src", src.Address(), "hash", vote.Digest, "msgSize", size)
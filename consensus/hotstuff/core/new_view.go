@@ -82,7 +82,7 @@ func (c *core) handleNewView(data *hotstuff.Message, src hotstuff.Validator) err
 
 	logger.Trace("handleNewView", "msg", msgTyp, "src", src.Address(), "prepareQC", msg.PrepareQC.Hash)
 
-	if size := c.current.NewViewSize(); size >= c.Q() && c.currentState() < StateHighQC {
+	if size := c.current.NewViewSize(); c.valSet.QuorumReached(size) && c.currentState() < StateHighQC {
 		highQC := c.getHighQC()
 		c.current.SetHighQC(highQC)
 		c.current.SetState(StateHighQC)
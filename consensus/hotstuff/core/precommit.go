@@ -52,7 +52,7 @@ func (c *core) handlePrepareVote(data *hotstuff.Message, src hotstuff.Validator)
 
 	logger.Trace("handlePrepareVote", "msg", msgTyp, "src", src.Address(), "hash", vote.Digest)
 
-	if size := c.current.PrepareVoteSize(); size >= c.Q() && c.currentState() < StatePrepared {
+	if size := c.current.PrepareVoteSize(); c.valSet.QuorumReached(size) && c.currentState() < StatePrepared {
 		seals := c.getMessageSeals(size)
 		newProposal, err := c.backend.PreCommit(c.current.Proposal(), seals)
 		if err != nil {
@@ -328,19 +328,10 @@ func (s *SignerImpl) wrapCommittedSeal(hash common.Hash) []byte {
 	return hash.Bytes()
 }
 
+// checkValidatorQuorum rejects committers listing a non-member or a validator
+// twice, or not forming a quorum as decided by valSet.QuorumReached.
 func checkValidatorQuorum(committers []common.Address, valSet hotstuff.ValidatorSet) error {
-	validators := valSet.Copy()
-	validSeal := 0
-	for _, addr := range committers {
-		if validators.RemoveValidator(addr) {
-			validSeal++
-			continue
-		}
-		return errInvalidCommittedSeals
-	}
-
-	// The length of validSeal should be larger than number of faulty node + 1
-	if validSeal <= validators.Q() {
+	if err := valSet.CheckQuorumStrict(committers); err != nil {
 		return errInvalidCommittedSeals
 	}
 	return nil
@@ -21,6 +21,7 @@ package signer
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"math/big"
 	"sort"
 	"strings"
 	"testing"
@@ -115,6 +116,25 @@ func TestFillExtraAfterCommit(t *testing.T) {
 
 var emptySigner = &SignerImpl{}
 
+func TestCheckValidatorQuorum(t *testing.T) {
+	addrs := make([]common.Address, 5)
+	for i := range addrs {
+		addrs[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+	}
+	valSet := validator.NewSet(addrs[:4], hotstuff.RoundRobin)
+
+	// the seals follow the quorum of the validator set, 3 of 4
+	for count := 0; count <= 4; count++ {
+		err := checkValidatorQuorum(addrs[:count], valSet)
+		if valSet.QuorumReached(count) != (err == nil) {
+			t.Errorf("count %d: error mismatch: have %v, quorum reached %v", count, err, valSet.QuorumReached(count))
+		}
+	}
+	assert.NoError(t, checkValidatorQuorum(addrs[:3], valSet))
+	assert.Equal(t, errInvalidCommittedSeals, checkValidatorQuorum([]common.Address{addrs[0], addrs[1], addrs[1]}, valSet))
+	assert.Equal(t, errInvalidCommittedSeals, checkValidatorQuorum(append(addrs[:3:3], addrs[4]), valSet))
+}
+
 type Keys []*ecdsa.PrivateKey
 
 func (slice Keys) Len() int {
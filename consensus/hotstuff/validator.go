@@ -19,6 +19,7 @@
 package hotstuff
 
 import (
+	"context"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -28,6 +29,17 @@ type Validator interface {
 	// Address returns address
 	Address() common.Address
 
+	// Weight returns the voting power of the validator
+	Weight() uint64
+
+	// BLSPublicKey returns the key verifying the aggregated signatures of
+	// the validator, nil if it has none
+	BLSPublicKey() []byte
+
+	// ProposerEligible reports whether the validator may be picked as
+	// proposer, vote-only validators still count toward quorum
+	ProposerEligible() bool
+
 	// String representation of Validator
 	String() string
 }
@@ -53,42 +65,177 @@ func (slice Validators) Swap(i, j int) {
 type ValidatorSet interface {
 	// Calculate the proposer
 	CalcProposer(lastProposer common.Address, round uint64)
+	// Calculate the proposer like CalcProposer, but give up once ctx is done
+	// and report a failed VRF selection instead of falling back
+	CalcProposerCtx(ctx context.Context, lastProposer common.Address, round uint64) error
+	// Calculate the proposer of the given round without changing the current one
+	ProposerForRound(lastProposer common.Address, round uint64) Validator
+	// Get the proposer following the current one without changing it
+	NextProposer() Validator
+	// Calculate the proposers of rounds [0, rounds) without changing the current one
+	ProposerSchedule(lastProposer common.Address, rounds uint64) []common.Address
+	// Get the raw seed the round robin and sticky selectors derive from lastProposer and round
+	Seed(lastProposer common.Address, round uint64) uint64
+	// Calculate the proposer from a seed such as the last block hash
+	CalcProposerFromSeed(seed common.Hash, round uint64)
+	// Get the seed last given to CalcProposerFromSeed
+	ProposerSeed() common.Hash
+	// Set the seed of the per epoch proposer permutation
+	SetEpochSeed(seed common.Hash)
+	// Get the seed of the per epoch proposer permutation
+	EpochSeed() common.Hash
+	// Calculate the proposer of the round counted across epochs
+	CalcProposerGlobal(lastProposer common.Address, epoch, round, roundsPerEpoch uint64)
+	// Calculate the proposer of the round from the stored last proposer
+	CalcProposerByRound(round uint64)
+	// Store the last proposer used by CalcProposerByRound
+	SetLastProposer(addr common.Address)
+	// Get the stored last proposer
+	LastProposer() common.Address
+	// Set the proposer of round 0 when there is no last proposer
+	SetGenesisProposer(addr common.Address)
+	// Get the proposer of round 0 when there is no last proposer
+	GenesisProposer() common.Address
+	// Forbid round robin to pick the last proposer again
+	SetNoRepeatProposer(noRepeat bool)
+	// Check whether round robin may pick the last proposer again
+	NoRepeatProposer() bool
+	// Move the proposer on to the next validator
+	AdvanceProposer()
 	// Calculate the proposer with index
 	CalcProposerByIndex(index uint64)
 	// Return the validator size
 	Size() int
-	// Return the validator array
+	// Return a copy of the validator array
 	List() []Validator
-	// Return the validator address array
+	// Return a copy of the validator address array
 	AddressList() []common.Address
+	// Return the BLS public keys in validator order, nil for validators without one
+	BLSPublicKeys() [][]byte
 	// Get validator by index
 	GetByIndex(i uint64) Validator
+	// Get validator by index, return error if the index is out of range
+	MustGetByIndex(i uint64) (Validator, error)
+	// Return a copy of the validators keyed by address
+	AsMap() map[common.Address]Validator
 	// Get validator by given address
 	GetByAddress(addr common.Address) (int, Validator)
+	// Check whether the given address is a validator
+	Contains(addr common.Address) bool
+	// Check that the validator is the proposer of the round and its VRF proof is valid
+	VerifyProposer(addr common.Address, round uint64, proof []byte) error
 	// Get current proposer
 	GetProposer() Validator
 	// Check whether the validator with given address is a proposer
 	IsProposer(address common.Address) bool
+	// Check whether the validator with given address is the proposer of round after lastProposer
+	IsProposerForRound(address common.Address, lastProposer common.Address, round uint64) bool
+	// Check whether the validator with given index is a proposer
+	IsProposerIndex(i uint64) bool
+	// Get the index of current proposer, -1 if there is none
+	ProposerIndex() int
 	// Add validator
 	AddValidator(address common.Address) bool
 	// Remove validator
 	RemoveValidator(address common.Address) bool
-	// Copy validator set
+	// Remove validator and return it
+	RemoveValidatorGet(address common.Address) (Validator, bool)
+	// Remove validator with index
+	RemoveValidatorByIndex(i uint64) bool
+	// Add validators in batch, return the number of validators added
+	AddValidators(addrs []common.Address) int
+	// Remove validators in batch, return the number of validators removed
+	RemoveValidators(addrs []common.Address) int
+	// Jail excludes the validator from proposer rotation
+	Jail(address common.Address) bool
+	// Unjail returns the validator to proposer rotation
+	Unjail(address common.Address) bool
+	// Check whether the validator is excluded from proposer rotation
+	IsJailed(address common.Address) bool
+	// SetProposerEligible marks the validator as proposer or vote-only
+	SetProposerEligible(address common.Address, eligible bool) bool
+	// Copy validator set including the jailed validators, which selection depends on
 	Copy() ValidatorSet
+	// Copy validator set including the proposer and history state
+	DeepCopy() ValidatorSet
+	// Union creates a set of the validators of both sets
+	Union(other ValidatorSet) ValidatorSet
+	// Intersect creates a set of the validators common to both sets
+	Intersect(other ValidatorSet) ValidatorSet
+	// Subset creates a set of the given validators, which must all be members
+	Subset(addrs []common.Address) (ValidatorSet, error)
 	// ParticipantsNumber calculate invalid validator size
 	ParticipantsNumber(list []common.Address) int
+	// FilterMembers split the list into validators and non-validators
+	FilterMembers(list []common.Address) (members, nonMembers []common.Address)
+	// AbsentMembers return the validators missing from committers, sorted
+	AbsentMembers(committers []common.Address) []common.Address
 	// CheckQuorum check committers
 	CheckQuorum(committers []common.Address) error
+	// CheckQuorumStrict check committers and reject any non-member
+	CheckQuorumStrict(committers []common.Address) error
+	// CheckWeightedQuorum check committers hold more than 2/3 of the voting power
+	CheckWeightedQuorum(committers []common.Address) error
+	// CheckBitmapQuorum check the signers marked on a bitmap over the validator indexes
+	CheckBitmapQuorum(bitmap []byte) error
+	// Convert a signer bitmap over the validator indexes to the signer addresses
+	BitmapToAddresses(bitmap []byte) ([]common.Address, error)
+	// Convert signer addresses to a bitmap over the validator indexes
+	AddressesToBitmap(addrs []common.Address) ([]byte, error)
 	// Get the maximum number of faulty nodes
 	F() int
 	// Get the minimum number of quorum nodes
 	Q() int
+	// Get the number of distinct committers a quorum needs, Q under either fault model
+	QuorumSize() int
+	// Check whether count distinct committers reach the inclusive quorum threshold
+	QuorumReached(count int) bool
+	// Return a smallest list of committers forming a quorum
+	MinQuorumCommitters() []common.Address
+	// Check whether a quorum can be formed at all
+	CanReachQuorum() bool
+	// Check whether the set is large enough to tolerate a faulty node
+	HealthCheck() error
+	// Get the size and fault tolerance parameters at once
+	Params() ValidatorSetParams
+	// Get the sum of voting power, which equals Size for unweighted sets
+	TotalWeight() uint64
+	// Check that the voting power sums up to the expected total
+	ValidateTotalWeight(expected uint64) error
+	// Get the maximum voting power of faulty nodes
+	WeightedF() uint64
+	// Get the minimum voting power of quorum nodes
+	WeightedQ() uint64
 	// Get speaker policy
 	Policy() SelectProposerPolicy
+	// Return the fault model deciding F, Q and QuorumSize
+	FaultModel() FaultModel
 	// Cmp compare with another validator set, return false if not the same
 	Cmp(src ValidatorSet) bool
+	// EqualUnordered compare the members with an address list in any order
+	EqualUnordered(addrs []common.Address) bool
+	// Equal compare the ordered validators and the policy with another set
+	Equal(src ValidatorSet) bool
+	// Hash returns the commitment to the policy, the ordered validators and their weights
+	Hash() common.Hash
+	// Epoch returns the epoch the validator set belongs to
+	Epoch() uint64
+	// Transition creates the validator set of the given epoch
+	Transition(newAddrs []common.Address, epoch uint64) ValidatorSet
+	// EpochChanges returns the validators joined and left at the last transition
+	EpochChanges() (added, removed []common.Address)
 }
 
 // ----------------------------------------------------------------------------
 
+// ValidatorSetParams holds the size and fault tolerance parameters of a
+// validator set, taken from the same state.
+type ValidatorSetParams struct {
+	Size        int    // number of validators
+	F           int    // maximum number of faulty validators
+	Q           int    // minimum number of quorum validators
+	QuorumSize  int    // committers needed for a quorum, equal to Q
+	TotalWeight uint64 // sum of voting power
+}
+
 type ProposalSelector func(ValidatorSet, common.Address, uint64) Validator
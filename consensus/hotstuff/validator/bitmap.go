@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrBitmapOutOfRange is returned if a signer bitmap marks an index beyond
+// the validators of the set.
+var ErrBitmapOutOfRange = fmt.Errorf("%w: signer bit out of range", ErrInvalidParticipant)
+
+// CheckBitmapQuorum checks that the signers marked on bitmap form a quorum.
+// Bit i, the bit i%8 of byte i/8 counting from the least significant one,
+// marks the validator at index i of the sorted list as in QCs. Trailing zero
+// bytes are allowed, but a bit set beyond the last validator fails with
+// ErrBitmapOutOfRange. If any validator weighs other than 1, the signers must
+// hold a quorum of the voting power as for CheckWeightedQuorum.
+func (valSet *defaultSet) CheckBitmapQuorum(bitmap []byte) error {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	if len(valSet.validators) == 0 {
+		return ErrEmptySet
+	}
+	count, weight := 0, uint64(0)
+	err := valSet.forEachSigner(bitmap, func(idx int) {
+		count++
+		weight += valSet.validators[idx].Weight()
+	})
+	if err != nil {
+		return err
+	}
+	if valSet.weighted() {
+		if !valSet.weightedQuorum(weight) {
+			return ErrBelowQuorum
+		}
+		return nil
+	}
+	if !valSet.quorumReached(count) {
+		return ErrBelowQuorum
+	}
+	return nil
+}
+
+// BitmapToAddresses returns the addresses of the signers marked on bitmap, in
+// validator order. The bitmap layout is the one of CheckBitmapQuorum.
+func (valSet *defaultSet) BitmapToAddresses(bitmap []byte) ([]common.Address, error) {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	addrs := []common.Address{}
+	err := valSet.forEachSigner(bitmap, func(idx int) {
+		addrs = append(addrs, valSet.validators[idx].Address())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// AddressesToBitmap returns the bitmap marking the given signers, one bit per
+// validator rounded up to whole bytes. The bitmap layout is the one of
+// CheckBitmapQuorum. It fails with ErrNonMember if an address is not a
+// validator of the set.
+func (valSet *defaultSet) AddressesToBitmap(addrs []common.Address) ([]byte, error) {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	bitmap := make([]byte, (len(valSet.validators)+7)/8)
+	for _, addr := range addrs {
+		idx, ok := valSet.indexes[addr]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNonMember, addr.Hex())
+		}
+		bitmap[idx/8] |= 1 << (uint(idx) % 8)
+	}
+	return bitmap, nil
+}
+
+// forEachSigner calls fn with the index of every signer marked on bitmap, in
+// ascending order. It should be called with the read lock held.
+func (valSet *defaultSet) forEachSigner(bitmap []byte, fn func(idx int)) error {
+	for i, b := range bitmap {
+		for bit := 0; b != 0; bit, b = bit+1, b>>1 {
+			if b&1 == 0 {
+				continue
+			}
+			idx := i*8 + bit
+			if idx >= len(valSet.validators) {
+				return fmt.Errorf("%w: bit %d, have %d validators", ErrBitmapOutOfRange, idx, len(valSet.validators))
+			}
+			fn(idx)
+		}
+	}
+	return nil
+}
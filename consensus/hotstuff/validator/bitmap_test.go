@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBitmapQuorum(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(10), hotstuff.RoundRobin)
+	assert.Equal(t, 7, valSet.Q())
+
+	testCases := []struct {
+		bitmap []byte
+		err    error
+	}{
+		// indexes 0 to 5
+		{[]byte{0x3f}, ErrBelowQuorum},
+		// indexes 0 to 6
+		{[]byte{0x7f}, nil},
+		// indexes 2 to 8
+		{[]byte{0xfc, 0x01}, nil},
+		// indexes 4 to 9, padded
+		{[]byte{0xf0, 0x03, 0x00}, ErrBelowQuorum},
+		{[]byte{0xff, 0x03}, nil},
+		// index 10 is out of range
+		{[]byte{0x7f, 0x04}, ErrBitmapOutOfRange},
+		{[]byte{0xff, 0xff}, ErrBitmapOutOfRange},
+		{nil, ErrBelowQuorum},
+	}
+	for i, test := range testCases {
+		if err := valSet.CheckBitmapQuorum(test.bitmap); !errors.Is(err, test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+	assert.True(t, errors.Is(ErrBitmapOutOfRange, ErrInvalidParticipant))
+	assert.Equal(t, ErrEmptySet, newDefaultSet(nil, hotstuff.RoundRobin).CheckBitmapQuorum([]byte{0x01}))
+}
+
+func TestCheckBitmapQuorumWeighted(t *testing.T) {
+	valSet, err := NewWeightedSet(testAddresses(4), []uint64{1, 1, 1, 6}, hotstuff.RoundRobin)
+	if err != nil {
+		t.Fatalf("failed to create weighted set: %v", err)
+	}
+	// three of four validators, but only 3 of 9
+	assert.Equal(t, ErrBelowQuorum, valSet.CheckBitmapQuorum([]byte{0x07}))
+	// 6 of 9 is not more than 2/3
+	assert.Equal(t, ErrBelowQuorum, valSet.CheckBitmapQuorum([]byte{0x08}))
+	assert.NoError(t, valSet.CheckBitmapQuorum([]byte{0x09}))
+}
+
+func TestBitmapAddresses(t *testing.T) {
+	addrs := testAddresses(10)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+
+	bitmap, err := valSet.AddressesToBitmap([]common.Address{addrs[9], addrs[0], addrs[3], addrs[8], addrs[3]})
+	if err != nil {
+		t.Fatalf("failed to encode bitmap: %v", err)
+	}
+	assert.Equal(t, []byte{0x09, 0x03}, bitmap)
+	signers, err := valSet.BitmapToAddresses(bitmap)
+	if err != nil {
+		t.Fatalf("failed to decode bitmap: %v", err)
+	}
+	assert.Equal(t, []common.Address{addrs[0], addrs[3], addrs[8], addrs[9]}, signers)
+
+	// round trip from any subset, in validator order
+	picks := []int{0, 2, 4, 5, 7, 9}
+	for mask := 0; mask < 1<<len(picks); mask++ {
+		var subset []common.Address
+		for i, pick := range picks {
+			if mask&(1<<i) != 0 {
+				subset = append(subset, addrs[pick])
+			}
+		}
+		bitmap, err := valSet.AddressesToBitmap(subset)
+		assert.NoError(t, err)
+		have, err := valSet.BitmapToAddresses(bitmap)
+		assert.NoError(t, err)
+		if subset == nil {
+			subset = []common.Address{}
+		}
+		assert.Equal(t, subset, have)
+		assert.Equal(t, valSet.CheckQuorum(subset), valSet.CheckBitmapQuorum(bitmap))
+	}
+
+	if _, err := valSet.AddressesToBitmap([]common.Address{common.HexToAddress("0x100")}); !errors.Is(err, ErrNonMember) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNonMember)
+	}
+	if _, err := valSet.BitmapToAddresses([]byte{0x01, 0x04}); !errors.Is(err, ErrBitmapOutOfRange) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrBitmapOutOfRange)
+	}
+	empty, err := newDefaultSet(nil, hotstuff.RoundRobin).AddressesToBitmap(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+// Builder assembles a validator set step by step, e.g.
+//
+//	NewBuilder().AddAddress(a).AddWeighted(b, 5).WithPolicy(hotstuff.Sticky).WithMaxSize(10).Build()
+//
+// Nothing is checked until Build, which reports the first problem found.
+type Builder struct {
+	validators hotstuff.Validators
+	policy     hotstuff.SelectProposerPolicy
+	faultModel hotstuff.FaultModel
+	maxSize    int
+	jailed     []common.Address
+}
+
+// NewBuilder creates a builder of an empty round robin BFT set.
+func NewBuilder() *Builder {
+	return &Builder{policy: hotstuff.RoundRobin, faultModel: hotstuff.BFT}
+}
+
+// AddAddress adds a validator of weight 1.
+func (b *Builder) AddAddress(addr common.Address) *Builder {
+	b.validators = append(b.validators, New(addr))
+	return b
+}
+
+// AddWeighted adds a validator of the given voting power.
+func (b *Builder) AddWeighted(addr common.Address, weight uint64) *Builder {
+	b.validators = append(b.validators, NewWithWeight(addr, weight))
+	return b
+}
+
+// WithPolicy sets the proposer policy, round robin by default.
+func (b *Builder) WithPolicy(policy hotstuff.SelectProposerPolicy) *Builder {
+	b.policy = policy
+	return b
+}
+
+// WithFaultModel sets the fault model, BFT by default.
+func (b *Builder) WithFaultModel(model hotstuff.FaultModel) *Builder {
+	b.faultModel = model
+	return b
+}
+
+// WithMaxSize caps the size of the set, see NewSetWithLimit.
+func (b *Builder) WithMaxSize(maxSize int) *Builder {
+	b.maxSize = maxSize
+	return b
+}
+
+// WithJailed jails the given validator once the set is built.
+func (b *Builder) WithJailed(addr common.Address) *Builder {
+	b.jailed = append(b.jailed, addr)
+	return b
+}
+
+// Build creates the validator set. It fails with ErrEmptySet if no validator
+// was added, with ErrZeroAddress, ErrInvalidParticipant or ErrSetFull if a
+// validator is the zero address, is added twice, weighs nothing or exceeds
+// the maximum size, and with ErrNonMember if a jailed address was not added.
+func (b *Builder) Build() (hotstuff.ValidatorSet, error) {
+	if len(b.validators) == 0 {
+		return nil, ErrEmptySet
+	}
+	seen := make(map[common.Address]struct{}, len(b.validators))
+	for _, val := range b.validators {
+		addr := val.Address()
+		if emptyAddress(addr) {
+			return nil, ErrZeroAddress
+		}
+		if _, ok := seen[addr]; ok {
+			return nil, fmt.Errorf("%w: %s added twice", ErrInvalidParticipant, addr.Hex())
+		}
+		if val.Weight() == 0 {
+			return nil, fmt.Errorf("%w: %s has no weight", ErrInvalidParticipant, addr.Hex())
+		}
+		seen[addr] = struct{}{}
+	}
+	if err := checkTotalWeight(b.validators); err != nil {
+		return nil, err
+	}
+	if b.maxSize > 0 && len(b.validators) > b.maxSize {
+		return nil, fmt.Errorf("%w: have %d validators, want at most %d", ErrSetFull, len(b.validators), b.maxSize)
+	}
+	for _, addr := range b.jailed {
+		if _, ok := seen[addr]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNonMember, addr.Hex())
+		}
+	}
+
+	validators := make(hotstuff.Validators, len(b.validators))
+	copy(validators, b.validators)
+	valSet := initDefaultSet(&defaultSet{faultModel: b.faultModel, maxSize: b.maxSize}, validators, b.policy)
+	for _, addr := range b.jailed {
+		valSet.Jail(addr)
+	}
+	return valSet, nil
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet, err := NewBuilder().
+		AddAddress(addrs[2]).
+		AddWeighted(addrs[0], 5).
+		AddAddress(addrs[1]).
+		WithPolicy(hotstuff.Sticky).
+		WithFaultModel(hotstuff.CFT).
+		WithMaxSize(3).
+		WithJailed(addrs[1]).
+		Build()
+	if err != nil {
+		t.Fatalf("failed to build set: %v", err)
+	}
+	assert.Equal(t, addrs[:3], valSet.AddressList())
+	assert.Equal(t, hotstuff.Sticky, valSet.Policy())
+	assert.Equal(t, hotstuff.CFT, valSet.FaultModel())
+	assert.Equal(t, uint64(7), valSet.TotalWeight())
+	assert.True(t, valSet.IsJailed(addrs[1]))
+	assert.False(t, valSet.AddValidator(addrs[3]))
+
+	// the builder can be reused, the sets it built are independent
+	builder := NewBuilder().AddAddress(addrs[0])
+	first, _ := builder.Build()
+	second, _ := builder.AddAddress(addrs[1]).Build()
+	assert.Equal(t, 1, first.Size())
+	assert.Equal(t, 2, second.Size())
+	assert.Equal(t, hotstuff.RoundRobin, first.Policy())
+	assert.Equal(t, hotstuff.BFT, first.FaultModel())
+
+	testCases := []struct {
+		builder *Builder
+		err     error
+	}{
+		{NewBuilder(), ErrEmptySet},
+		{NewBuilder().AddAddress(common.Address{}), ErrZeroAddress},
+		{NewBuilder().AddAddress(addrs[0]).AddWeighted(addrs[0], 2), ErrInvalidParticipant},
+		{NewBuilder().AddWeighted(addrs[0], 0), ErrInvalidParticipant},
+		{NewBuilder().AddAddress(addrs[0]).AddAddress(addrs[1]).WithMaxSize(1), ErrSetFull},
+		{NewBuilder().AddAddress(addrs[0]).WithJailed(addrs[1]), ErrNonMember},
+		{NewBuilder().AddWeighted(addrs[0], 1<<63).AddWeighted(addrs[1], 1<<63), ErrWeightOverflow},
+	}
+	for i, test := range testCases {
+		if _, err := test.builder.Build(); !errors.Is(err, test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+}
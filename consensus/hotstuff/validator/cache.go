@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	lru "github.com/hashicorp/golang-lru"
+
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+// defaultSetCacheSize is the number of sets kept by caches which don't
+// configure it, see NewSetCache.
+const defaultSetCacheSize = 128
+
+// SetCache keeps the validator sets of recently requested heights, so that
+// validating old blocks doesn't rebuild them from the extra data every time.
+// The least recently used set is evicted once the capacity is reached. It is
+// safe for concurrent use.
+type SetCache struct {
+	sets *lru.Cache // height to read only validator set
+}
+
+// NewSetCache creates a cache of up to capacity sets, a non positive capacity
+// selects the default of 128.
+func NewSetCache(capacity int) *SetCache {
+	if capacity <= 0 {
+		capacity = defaultSetCacheSize
+	}
+	sets, _ := lru.New(capacity)
+	return &SetCache{sets: sets}
+}
+
+// Get returns the set of the given height, read only as it is shared with
+// every other caller.
+func (c *SetCache) Get(height uint64) (hotstuff.ValidatorSet, bool) {
+	if v, ok := c.sets.Get(height); ok {
+		return v.(hotstuff.ValidatorSet), true
+	}
+	return nil, false
+}
+
+// Put stores the set of the given height, replacing any previous one. A deep
+// copy of the set is stored read only, so callers keep the right to change
+// their own set without altering the cached one.
+func (c *SetCache) Put(height uint64, valSet hotstuff.ValidatorSet) {
+	if valSet == nil {
+		return
+	}
+	c.sets.Add(height, ReadOnly(valSet.DeepCopy()))
+}
+
+// Len returns the number of cached sets.
+func (c *SetCache) Len() int {
+	return c.sets.Len()
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCache(t *testing.T) {
+	addrs := testAddresses(8)
+	cache := NewSetCache(3)
+	for height := uint64(1); height <= 3; height++ {
+		cache.Put(height, newDefaultSet(addrs[:height+3], hotstuff.RoundRobin))
+	}
+	assert.Equal(t, 3, cache.Len())
+
+	// height 1 is used, so height 2 is the least recently used one
+	valSet, ok := cache.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, addrs[:4], valSet.AddressList())
+	cache.Put(4, newDefaultSet(addrs, hotstuff.RoundRobin))
+	_, ok = cache.Get(2)
+	assert.False(t, ok)
+	for _, height := range []uint64{1, 3, 4} {
+		_, ok := cache.Get(height)
+		assert.True(t, ok, "height %d", height)
+	}
+
+	// then height 1 is, as 3 and 4 were used since
+	cache.Put(5, newDefaultSet(addrs, hotstuff.RoundRobin))
+	_, ok = cache.Get(1)
+	assert.False(t, ok)
+	assert.Equal(t, 3, cache.Len())
+
+	// cached sets are read only
+	valSet, _ = cache.Get(3)
+	assert.False(t, valSet.AddValidator(addrs[7]))
+	assert.Equal(t, 6, valSet.Size())
+
+	// and independent of the set which was put
+	live := newDefaultSet(addrs[:3], hotstuff.RoundRobin)
+	live.CalcProposer(addrs[0], 0)
+	cache.Put(6, live)
+	live.AddValidator(addrs[7])
+	live.CalcProposer(addrs[1], 0)
+	valSet, _ = cache.Get(6)
+	assert.Equal(t, addrs[:3], valSet.AddressList())
+	assert.Equal(t, addrs[1], valSet.GetProposer().Address())
+
+	cache.Put(7, nil)
+	_, ok = cache.Get(7)
+	assert.False(t, ok)
+
+	// the capacity defaults to 128
+	cache = NewSetCache(0)
+	for height := uint64(0); height <= defaultSetCacheSize; height++ {
+		cache.Put(height, valSet)
+	}
+	assert.Equal(t, defaultSetCacheSize, cache.Len())
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+// Diff returns the validators which joined and left between two validator
+// sets, both sorted in validator order.
+func Diff(old, new hotstuff.ValidatorSet) (added, removed []common.Address) {
+	added, removed = diffAddresses(old.AddressList(), new.AddressList())
+	sortAddresses(added)
+	sortAddresses(removed)
+	if added == nil {
+		added = []common.Address{}
+	}
+	if removed == nil {
+		removed = []common.Address{}
+	}
+	return added, removed
+}
+
+// ChangeKind classifies how the membership of a validator set changed.
+type ChangeKind int
+
+const (
+	ChangeNone     ChangeKind = iota // same validators
+	ChangeAdded                      // validators joined, none left
+	ChangeRemoved                    // validators left, none joined
+	ChangeReplaced                   // validators both joined and left
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeNone:
+		return "none"
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeReplaced:
+		return "replaced"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change classifies the membership change from the parent set to the current
+// one, e.g. the sets parsed from the extra data of a header and its parent. A
+// nil set is taken as empty.
+func Change(parent, current hotstuff.ValidatorSet) ChangeKind {
+	added, removed := diffAddresses(addressesOf(parent), addressesOf(current))
+	switch {
+	case len(added) > 0 && len(removed) > 0:
+		return ChangeReplaced
+	case len(added) > 0:
+		return ChangeAdded
+	case len(removed) > 0:
+		return ChangeRemoved
+	}
+	return ChangeNone
+}
+
+// Changed reports whether the membership changed from the parent set to the
+// current one, which triggers the epoch logic. The order and configuration of
+// the sets are ignored, see Equal for those.
+func Changed(parent, current hotstuff.ValidatorSet) bool {
+	return Change(parent, current) != ChangeNone
+}
+
+func addressesOf(valSet hotstuff.ValidatorSet) []common.Address {
+	if valSet == nil {
+		return nil
+	}
+	return valSet.AddressList()
+}
+
+// diffAddresses returns the addresses only present in new and the addresses
+// only present in old, both in the order they appear in their list.
+func diffAddresses(old, new []common.Address) (added, removed []common.Address) {
+	oldSet := make(map[common.Address]struct{}, len(old))
+	for _, addr := range old {
+		oldSet[addr] = struct{}{}
+	}
+	newSet := make(map[common.Address]struct{}, len(new))
+	for _, addr := range new {
+		newSet[addr] = struct{}{}
+		if _, ok := oldSet[addr]; !ok {
+			added = append(added, addr)
+		}
+	}
+	for _, addr := range old {
+		if _, ok := newSet[addr]; !ok {
+			removed = append(removed, addr)
+		}
+	}
+	return added, removed
+}
+
+// sortAddresses sorts addresses in the same order as hotstuff.Validators.
+func sortAddresses(addrs []common.Address) {
+	sort.Slice(addrs, func(i, j int) bool {
+		return strings.Compare(addrs[i].String(), addrs[j].String()) < 0
+	})
+}
+
+// uniqueSortedAddresses returns a sorted copy of addrs without duplicates.
+func uniqueSortedAddresses(addrs []common.Address) []common.Address {
+	sorted := copyAddresses(addrs)
+	sortAddresses(sorted)
+	unique := sorted[:0]
+	for i, addr := range sorted {
+		if i == 0 || addr != sorted[i-1] {
+			unique = append(unique, addr)
+		}
+	}
+	return unique
+}
+
+// Union returns a new set holding the validators of both sets, the policy,
+// order, selector and jailed validators are the ones of valSet, as are the
+// weights of shared validators. So is the cap, unless the union exceeds it.
+func (valSet *defaultSet) Union(other hotstuff.ValidatorSet) hotstuff.ValidatorSet {
+	others := other.List()
+
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	validators := make(hotstuff.Validators, 0, len(valSet.validators)+len(others))
+	validators = append(validators, valSet.validators...)
+	for _, v := range others {
+		if _, ok := valSet.indexes[v.Address()]; !ok {
+			validators = append(validators, NewWithWeight(v.Address(), v.Weight()))
+		}
+	}
+	return valSet.derive(validators)
+}
+
+// Intersect returns a new set holding the validators which are members of
+// both sets, the policy, order, selector, jailed validators and weights are
+// the ones of valSet.
+func (valSet *defaultSet) Intersect(other hotstuff.ValidatorSet) hotstuff.ValidatorSet {
+	others := other.AsMap()
+
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	validators := make(hotstuff.Validators, 0, len(valSet.validators))
+	for _, v := range valSet.validators {
+		if _, ok := others[v.Address()]; ok {
+			validators = append(validators, v)
+		}
+	}
+	return valSet.derive(validators)
+}
+
+// Subset returns a new set holding only the given validators, configured like
+// valSet as for Intersect, e.g. to simulate one side of a network partition.
+// Every address must be a member, otherwise it fails with ErrNonMember naming
+// the first one which is not. Duplicated addresses are ignored.
+func (valSet *defaultSet) Subset(addrs []common.Address) (hotstuff.ValidatorSet, error) {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	keep := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		if _, ok := valSet.indexes[addr]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNonMember, addr.Hex())
+		}
+		keep[addr] = struct{}{}
+	}
+	validators := make(hotstuff.Validators, 0, len(keep))
+	for _, v := range valSet.validators {
+		if _, ok := keep[v.Address()]; ok {
+			validators = append(validators, v)
+		}
+	}
+	return valSet.derive(validators), nil
+}
+
+// derive creates a set of the given validators configured like valSet, it
+// should be called with the read lock held.
+func (valSet *defaultSet) derive(validators hotstuff.Validators) *defaultSet {
+	set := valSet.newLike(validators)
+	set.selector = valSet.selector
+	set.jailed = valSet.jailedMembers(set)
+	set.vrf = valSet.vrf
+	set.vrfOutput = common.CopyBytes(valSet.vrfOutput)
+	set.seed = valSet.seed
+	set.epochSeed = valSet.epochSeed
+	return set
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	addrs := testAddresses(6)
+
+	old := NewSet(addrs[:4], hotstuff.RoundRobin)
+	added, removed := Diff(old, old.Copy())
+	assert.Equal(t, []common.Address{}, added)
+	assert.Equal(t, []common.Address{}, removed)
+
+	new := NewSet([]common.Address{addrs[5], addrs[1], addrs[4], addrs[3]}, hotstuff.RoundRobin)
+	added, removed = Diff(old, new)
+	assert.Equal(t, []common.Address{addrs[4], addrs[5]}, added)
+	assert.Equal(t, []common.Address{addrs[0], addrs[2]}, removed)
+
+	disjoint := NewSet(addrs[4:], hotstuff.RoundRobin)
+	added, removed = Diff(old, disjoint)
+	assert.Equal(t, disjoint.AddressList(), added)
+	assert.Equal(t, old.AddressList(), removed)
+}
+
+func TestCmpDuplicates(t *testing.T) {
+	addrs := testAddresses(3)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+
+	// a set of the same size listing one member twice and missing another
+	dup := &defaultSet{validators: hotstuff.Validators{New(addrs[0]), New(addrs[0]), New(addrs[1])}}
+	assert.False(t, valSet.Cmp(dup))
+
+	// duplicates alone don't make sets differ
+	dup.validators = append(dup.validators, New(addrs[2]))
+	assert.True(t, valSet.Cmp(dup))
+}
+
+func TestEqualUnordered(t *testing.T) {
+	addrs := testAddresses(6)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+
+	shuffled := copyAddresses(addrs)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	given := copyAddresses(shuffled)
+	assert.True(t, valSet.EqualUnordered(shuffled))
+	assert.Equal(t, given, shuffled, "input reordered")
+	assert.True(t, valSet.EqualUnordered(append(shuffled, addrs[3])))
+
+	assert.False(t, valSet.EqualUnordered(shuffled[1:]))
+	assert.False(t, valSet.EqualUnordered(append(shuffled[1:], common.HexToAddress("0x100"))))
+	assert.False(t, valSet.EqualUnordered(nil))
+	assert.True(t, newDefaultSet(nil, hotstuff.RoundRobin).EqualUnordered(nil))
+}
+
+func TestUnionIntersect(t *testing.T) {
+	addrs := testAddresses(6)
+	primary, _ := NewWeightedSet(addrs[:4], []uint64{1, 2, 3, 4}, hotstuff.Sticky)
+	overlapping, _ := NewWeightedSet(addrs[2:], []uint64{10, 10, 10, 10}, hotstuff.RoundRobin)
+	disjoint := newDefaultSet(addrs[4:], hotstuff.RoundRobin)
+
+	union := primary.Union(overlapping)
+	assert.Equal(t, addrs, union.AddressList())
+	assert.Equal(t, hotstuff.Sticky, union.Policy())
+	// shared validators keep the weights of the primary
+	_, val := union.GetByAddress(addrs[3])
+	assert.Equal(t, uint64(4), val.Weight())
+	_, val = union.GetByAddress(addrs[5])
+	assert.Equal(t, uint64(10), val.Weight())
+
+	intersect := primary.Intersect(overlapping)
+	assert.Equal(t, addrs[2:4], intersect.AddressList())
+	assert.Equal(t, hotstuff.Sticky, intersect.Policy())
+	assert.Equal(t, uint64(7), intersect.TotalWeight())
+
+	assert.Equal(t, append(addrs[:4:4], addrs[4:]...), primary.Union(disjoint).AddressList())
+	assert.Equal(t, 0, primary.Intersect(disjoint).Size())
+
+	// the sources are left untouched
+	assert.Equal(t, 4, primary.Size())
+	assert.Equal(t, 4, overlapping.Size())
+}
+
+func TestSubset(t *testing.T) {
+	addrs := testAddresses(7)
+	valSet := newDefaultSet(addrs, hotstuff.Sticky)
+
+	// a 5/2 partition: only the larger side holds the quorum of 5 validators
+	major, err := valSet.Subset([]common.Address{addrs[5], addrs[0], addrs[3], addrs[6], addrs[1], addrs[0]})
+	if err != nil {
+		t.Fatalf("failed to create subset: %v", err)
+	}
+	assert.Equal(t, []common.Address{addrs[0], addrs[1], addrs[3], addrs[5], addrs[6]}, major.AddressList())
+	assert.Equal(t, hotstuff.Sticky, major.Policy())
+	assert.True(t, major.CanReachQuorum())
+
+	minor, err := valSet.Subset([]common.Address{addrs[2], addrs[4]})
+	if err != nil {
+		t.Fatalf("failed to create subset: %v", err)
+	}
+	assert.ErrorIs(t, valSet.CheckQuorum(minor.AddressList()), ErrBelowQuorum)
+	assert.NoError(t, valSet.CheckQuorum(major.AddressList()))
+
+	// the subset is independent of its source
+	major.RemoveValidator(addrs[0])
+	assert.Equal(t, 7, valSet.Size())
+
+	outsider := common.HexToAddress("0x100")
+	if _, err := valSet.Subset([]common.Address{addrs[0], outsider}); !errors.Is(err, ErrNonMember) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNonMember)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	addrs := testAddresses(6)
+	parent := NewSet(addrs[:4], hotstuff.RoundRobin)
+
+	testCases := []struct {
+		current []common.Address
+		kind    ChangeKind
+	}{
+		{addrs[:4], ChangeNone},
+		{[]common.Address{addrs[3], addrs[1], addrs[2], addrs[0]}, ChangeNone},
+		{addrs[:5], ChangeAdded},
+		{addrs[1:4], ChangeRemoved},
+		{addrs[1:5], ChangeReplaced},
+		{nil, ChangeRemoved},
+	}
+	for i, test := range testCases {
+		current := NewSet(test.current, hotstuff.Sticky)
+		if kind := Change(parent, current); kind != test.kind {
+			t.Errorf("test %d: kind mismatch: have %v, want %v", i, kind, test.kind)
+		}
+		assert.Equal(t, test.kind != ChangeNone, Changed(parent, current), "test %d", i)
+	}
+	// a missing parent set counts as empty
+	assert.Equal(t, ChangeAdded, Change(nil, parent))
+	assert.Equal(t, ChangeNone, Change(nil, nil))
+	assert.Equal(t, "replaced", ChangeReplaced.String())
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// assertSelectorConsistent checks that a and b pick the same proposers for
+// rounds rounds after every validator of a and after no last proposer. Copies
+// rebuild their selector from the policy, so a copy picking differently from
+// its original would let nodes diverge.
+func assertSelectorConsistent(t *testing.T, a, b hotstuff.ValidatorSet, rounds int) {
+	t.Helper()
+	lasts := append(a.AddressList(), common.Address{})
+	for _, last := range lasts {
+		for round := uint64(0); round < uint64(rounds); round++ {
+			have, want := b.ProposerForRound(last, round), a.ProposerForRound(last, round)
+			if (have == nil) != (want == nil) || (have != nil && have.Address() != want.Address()) {
+				t.Fatalf("%v: last %v, round %d: proposer mismatch: have %v, want %v", a.Policy(), last, round, have, want)
+			}
+		}
+	}
+}
+
+func TestSelectorConsistency(t *testing.T) {
+	addrs := testAddresses(7)
+	policies := []hotstuff.SelectProposerPolicy{hotstuff.RoundRobin, hotstuff.Sticky, hotstuff.VRF, hotstuff.WeightedRoundRobin, hotstuff.Fixed, hotstuff.HashSeeded, hotstuff.Shuffle}
+	for _, policy := range policies {
+		valSet, _ := NewWeightedSet(addrs, []uint64{1, 2, 3, 1, 2, 3, 1}, policy)
+		assertSelectorConsistent(t, valSet, valSet.Copy(), 30)
+
+		// every piece of state a selector reads must be carried over
+		set := valSet.(*defaultSet)
+		set.SetEpochSeed(crypto.Keccak256Hash([]byte("epoch")))
+		set.CalcProposerFromSeed(crypto.Keccak256Hash([]byte("block")), 0)
+		set.vrfOutput = []byte{0x01, 0x02}
+		set.SetGenesisProposer(addrs[3])
+		set.SetNoRepeatProposer(true)
+		set.Jail(addrs[2])
+		set.SetProposerEligible(addrs[5], false)
+		set.AddValidator(common.HexToAddress("0x100"))
+		set.RemoveValidator(addrs[0])
+
+		assertSelectorConsistent(t, valSet, valSet.DeepCopy(), 30)
+		assertSelectorConsistent(t, valSet, ReadOnly(valSet), 30)
+		assertSelectorConsistent(t, valSet, valSet.Copy(), 30)
+		assertSelectorConsistent(t, valSet, valSet.Union(valSet), 30)
+		assertSelectorConsistent(t, valSet, valSet.Intersect(valSet), 30)
+		subset, _ := valSet.Subset(valSet.AddressList())
+		assertSelectorConsistent(t, valSet, subset, 30)
+	}
+}
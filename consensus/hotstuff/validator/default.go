@@ -19,258 +19,1701 @@
 package validator
 
 import (
+	"context"
 	"errors"
-	"math"
-	"reflect"
+	"fmt"
+	"math/bits"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/log"
 )
 
-var ErrInvalidParticipant = errors.New("invalid participants")
+var (
+	// ErrInvalidParticipant is wrapped by every error about the committers or
+	// the members of a set, so that errors.Is matches all of them.
+	ErrInvalidParticipant = errors.New("invalid participants")
+
+	// ErrBelowQuorum is returned by the quorum checks if the committers do not
+	// form a quorum.
+	ErrBelowQuorum = fmt.Errorf("%w: below quorum", ErrInvalidParticipant)
+
+	// ErrEmptySet is returned if a quorum is checked against, or a set is
+	// created without, any validator.
+	ErrEmptySet = fmt.Errorf("%w: empty validator set", ErrInvalidParticipant)
+
+	// ErrNonMember is returned by the strict quorum check if a committer is
+	// not a validator of the set.
+	ErrNonMember = fmt.Errorf("%w: committer is not a validator", ErrInvalidParticipant)
+
+	// ErrDuplicateCommitter is returned by the strict quorum check if a
+	// validator is listed more than once among the committers.
+	ErrDuplicateCommitter = fmt.Errorf("%w: duplicate committer", ErrInvalidParticipant)
+
+	// ErrTooManyCommitters is returned by the strict quorum check if there are
+	// more committers than validators, which can only be caused by duplicated
+	// or non-member committers.
+	ErrTooManyCommitters = fmt.Errorf("%w: more committers than validators", ErrInvalidParticipant)
+
+	// ErrZeroAddress is returned if the zero address is given as a validator.
+	ErrZeroAddress = fmt.Errorf("%w: zero address", ErrInvalidParticipant)
+
+	// ErrWeightOverflow is returned if the voting power of the validators does
+	// not fit in 64 bits, so that the weighted quorum could not be computed.
+	ErrWeightOverflow = fmt.Errorf("%w: total weight overflows", ErrInvalidParticipant)
+
+	// ErrSetFull is returned if a set would grow beyond its maximum size.
+	ErrSetFull = errors.New("validator set is full")
+
+	// ErrTooFewValidators is returned by the health check if the set can not
+	// tolerate a single faulty validator.
+	ErrTooFewValidators = errors.New("too few validators")
+
+	// ErrWeightMismatch is returned if the voting power of a set differs from
+	// the expected total.
+	ErrWeightMismatch = errors.New("total weight mismatch")
+)
 
 type defaultValidator struct {
-	address common.Address
+	address   common.Address
+	weight    uint64
+	blsPubKey []byte // nil for legacy validators without a BLS key
+	eligible  bool   // may be picked as proposer, vote-only validators may not
 }
 
 func (val *defaultValidator) Address() common.Address {
 	return val.address
 }
 
+func (val *defaultValidator) Weight() uint64 {
+	return val.weight
+}
+
+// BLSPublicKey returns a copy of the BLS public key, nil if there is none.
+func (val *defaultValidator) BLSPublicKey() []byte {
+	return common.CopyBytes(val.blsPubKey)
+}
+
+func (val *defaultValidator) ProposerEligible() bool {
+	return val.eligible
+}
+
 func (val *defaultValidator) String() string {
 	return val.Address().String()
 }
 
+// cloneValidator returns a copy of val which may be changed without affecting
+// the sets sharing val.
+func cloneValidator(val hotstuff.Validator) *defaultValidator {
+	return &defaultValidator{
+		address:   val.Address(),
+		weight:    val.Weight(),
+		blsPubKey: val.BLSPublicKey(),
+		eligible:  val.ProposerEligible(),
+	}
+}
+
 // ----------------------------------------------------------------------------
 
 type defaultSet struct {
 	validators hotstuff.Validators
 	policy     hotstuff.SelectProposerPolicy
+	less       func(a, b hotstuff.Validator) bool // custom validator order, nil for ascending
+	unsorted   bool                               // keep the order given by the caller, see NewSetUnsorted
+	maxSize    int                                // cap on AddValidator(s), 0 for none
+	faultModel hotstuff.FaultModel                // BFT unless built by NewSetWithFaultModel
+	indexes    map[common.Address]int             // validator address to its index in the sorted list
+	f, q       int                                // cached F() and Q()
+	size       int32                              // len(validators), read by Size without the lock
+
+	proposer     hotstuff.Validator
+	lastProposer common.Address // proposer of the last block, see SetLastProposer
+	validatorMu  sync.RWMutex
+	selector     hotstuff.ProposalSelector
+
+	genesisProposer  common.Address // proposer of round 0 without a last proposer
+	noRepeatProposer bool           // round robin never picks the last proposer again
+	stickyBasis      common.Address // last proposer the sticky advances apply to
+	stickyAdvance    uint64         // AdvanceProposer calls since stickyBasis changed
+
+	vrf       VRF
+	vrfOutput []byte
+
+	seed      common.Hash // seed of the hash seeded policy, e.g. the last block hash
+	epochSeed common.Hash // seed of the shuffle policy, fixed for the epoch
 
-	proposer    hotstuff.Validator
-	validatorMu sync.RWMutex
-	selector    hotstuff.ProposalSelector
+	jailed map[common.Address]struct{} // replaced rather than mutated, see setJailed
+
+	history        *proposerHistory // recently computed proposers, see RecentProposers
+	metrics        *setMetrics
+	subscribers    map[uint64]chan MembershipEvent
+	nextSubscriber uint64
+
+	epoch   uint64
+	added   []common.Address // validators joined at the last epoch transition
+	removed []common.Address // validators left at the last epoch transition
 }
 
 func newDefaultSet(addrs []common.Address, policy hotstuff.SelectProposerPolicy) *defaultSet {
-	valSet := &defaultSet{}
+	validators := make([]hotstuff.Validator, len(addrs))
+	for i, addr := range addrs {
+		validators[i] = New(addr)
+	}
+	return newDefaultSetWithValidators(validators, policy)
+}
+
+func newDefaultSetWithValidators(validators hotstuff.Validators, policy hotstuff.SelectProposerPolicy) *defaultSet {
+	return newOrderedDefaultSet(validators, policy, nil)
+}
+
+// newOrderedDefaultSet creates a validator set sorted by less, see
+// lessValidator. A nil less keeps the default ascending address order.
+func newOrderedDefaultSet(validators hotstuff.Validators, policy hotstuff.SelectProposerPolicy, less func(a, b hotstuff.Validator) bool) *defaultSet {
+	return initDefaultSet(&defaultSet{less: less}, validators, policy)
+}
+
+// newUnsortedDefaultSet creates a validator set keeping the order of
+// validators, see NewSetUnsorted.
+func newUnsortedDefaultSet(validators hotstuff.Validators, policy hotstuff.SelectProposerPolicy) *defaultSet {
+	return initDefaultSet(&defaultSet{unsorted: true}, validators, policy)
+}
+
+// newLike creates a set of the given validators ordered and configured like
+// valSet. The cap of valSet is only carried over if the validators fit into
+// it, sets grown beyond it by Transition or Union have no cap rather than one
+// they already exceed.
+func (valSet *defaultSet) newLike(validators hotstuff.Validators) *defaultSet {
+	maxSize := valSet.maxSize
+	if maxSize > 0 && len(validators) > maxSize {
+		maxSize = 0
+	}
+	return initDefaultSet(&defaultSet{
+		less:             valSet.less,
+		unsorted:         valSet.unsorted,
+		maxSize:          maxSize,
+		faultModel:       valSet.faultModel,
+		genesisProposer:  valSet.genesisProposer,
+		noRepeatProposer: valSet.noRepeatProposer,
+	}, validators, valSet.policy)
+}
 
+// initDefaultSet fills in the validators and the policy of a set whose order
+// is already configured.
+func initDefaultSet(valSet *defaultSet, validators hotstuff.Validators, policy hotstuff.SelectProposerPolicy) *defaultSet {
 	valSet.policy = policy
-	// init validators
-	valSet.validators = make([]hotstuff.Validator, len(addrs))
-	for i, addr := range addrs {
-		valSet.validators[i] = New(addr)
+	// init validators, duplicated addresses keep their first occurrence and
+	// neither nil validators nor the zero address are ever stored
+	valSet.validators = make(hotstuff.Validators, 0, len(validators))
+	seen := make(map[common.Address]struct{}, len(validators))
+	for _, v := range validators {
+		if v == nil || emptyAddress(v.Address()) {
+			continue
+		}
+		if _, ok := seen[v.Address()]; ok {
+			continue
+		}
+		seen[v.Address()] = struct{}{}
+		valSet.validators = append(valSet.validators, v)
 	}
 	// sort validator
-	sort.Sort(valSet.validators)
+	valSet.sort()
+	valSet.refresh()
+	valSet.history = newProposerHistory(defaultProposerHistory)
 	// init proposer
 	if valSet.Size() > 0 {
 		valSet.proposer = valSet.GetByIndex(0)
 	}
-	valSet.selector = roundRobinSelector
-	if policy == hotstuff.Sticky {
-		valSet.selector = stickySelector
-	}
-	if policy == hotstuff.VRF {
-		valSet.selector = vrfSelector
-	}
+	valSet.selector = policySelector(policy)
 
 	return valSet
 }
 
+// SetSelector overrides the proposal selector derived from the policy, a nil
+// selector restores the policy default. A custom selector must be
+// deterministic: every node has to pick the same proposer given the same
+// validator set, last proposer and round.
+func (valSet *defaultSet) SetSelector(selector hotstuff.ProposalSelector) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+	if selector == nil {
+		selector = policySelector(valSet.policy)
+	}
+	valSet.selector = selector
+}
+
+// policySelector returns the proposal selector implementing the given policy,
+// unknown policies fall back to round robin.
+func policySelector(policy hotstuff.SelectProposerPolicy) hotstuff.ProposalSelector {
+	switch policy {
+	case hotstuff.Sticky:
+		return stickySelector
+	case hotstuff.VRF:
+		return vrfSelector
+	case hotstuff.WeightedRoundRobin:
+		return weightedRoundRobinSelector
+	case hotstuff.Fixed:
+		return fixedSelector
+	case hotstuff.HashSeeded:
+		return hashSeededSelector
+	case hotstuff.Shuffle:
+		return shuffleSelector
+	default:
+		return roundRobinSelector
+	}
+}
+
+// Size returns the number of validators. It is read atomically rather than
+// under the lock, as it is on the hot path of selectors and quorum checks.
 func (valSet *defaultSet) Size() int {
-	valSet.validatorMu.RLock()
-	defer valSet.validatorMu.RUnlock()
-	return len(valSet.validators)
+	return int(atomic.LoadInt32(&valSet.size))
 }
 
+// List returns a copy of the sorted validators, the caller owns the returned
+// slice and may modify it without affecting the set.
 func (valSet *defaultSet) List() []hotstuff.Validator {
 	valSet.validatorMu.RLock()
 	defer valSet.validatorMu.RUnlock()
-	return valSet.validators
+
+	validators := make([]hotstuff.Validator, len(valSet.validators))
+	copy(validators, valSet.validators)
+	return validators
 }
 
+// AddressList returns the addresses of the sorted validators in a newly
+// allocated slice owned by the caller.
 func (valSet *defaultSet) AddressList() []common.Address {
 	valSet.validatorMu.RLock()
 	defer valSet.validatorMu.RUnlock()
 
-	vals := make([]common.Address, valSet.Size())
-	for i, v := range valSet.List() {
+	vals := make([]common.Address, len(valSet.validators))
+	for i, v := range valSet.validators {
 		vals[i] = v.Address()
 	}
 	return vals
 }
 
+// BLSPublicKeys returns the BLS public keys of the sorted validators, for
+// aggregate signature verification. Legacy validators have a nil key.
+func (valSet *defaultSet) BLSPublicKeys() [][]byte {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	keys := make([][]byte, len(valSet.validators))
+	for i, v := range valSet.validators {
+		keys[i] = v.BLSPublicKey()
+	}
+	return keys
+}
+
 func (valSet *defaultSet) GetByIndex(i uint64) hotstuff.Validator {
 	valSet.validatorMu.RLock()
 	defer valSet.validatorMu.RUnlock()
-	if i < uint64(valSet.Size()) {
+	if i < uint64(len(valSet.validators)) {
 		return valSet.validators[i]
 	}
 	return nil
 }
 
+// MustGetByIndex works as GetByIndex but reports an out of range index with
+// an error wrapping ErrInvalidParticipant instead of returning nil.
+func (valSet *defaultSet) MustGetByIndex(i uint64) (hotstuff.Validator, error) {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	if i >= uint64(len(valSet.validators)) {
+		return nil, fmt.Errorf("%w: index %d out of range, size %d", ErrInvalidParticipant, i, len(valSet.validators))
+	}
+	return valSet.validators[i], nil
+}
+
+// AsMap returns the validators keyed by address. The map is a snapshot owned
+// by the caller, it is safe to retain and does not follow later changes.
+func (valSet *defaultSet) AsMap() map[common.Address]hotstuff.Validator {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	validators := make(map[common.Address]hotstuff.Validator, len(valSet.validators))
+	for _, v := range valSet.validators {
+		validators[v.Address()] = v
+	}
+	return validators
+}
+
 func (valSet *defaultSet) GetByAddress(addr common.Address) (int, hotstuff.Validator) {
-	for i, val := range valSet.List() {
-		if addr == val.Address() {
-			return i, val
-		}
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	if i, ok := valSet.indexes[addr]; ok {
+		return i, valSet.validators[i]
 	}
 	return -1, nil
 }
 
+// Contains reports whether addr is a validator of the set.
+func (valSet *defaultSet) Contains(addr common.Address) bool {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	_, ok := valSet.indexes[addr]
+	return ok
+}
+
+// sort orders the validators by lessValidator. The sort is stable and the
+// comparator is total on distinct addresses, so every node ends up with the
+// same order given the same members.
+func (valSet *defaultSet) sort() {
+	if valSet.unsorted {
+		return
+	}
+	sort.SliceStable(valSet.validators, func(i, j int) bool {
+		return valSet.lessValidator(valSet.validators[i], valSet.validators[j])
+	})
+}
+
+// lessValidator is the multi-key comparator of the validator list, the custom
+// order comes first if any, then the hex address as compared by
+// hotstuff.Validators. Validators the custom order considers equal, e.g. of
+// equal weight, are thus still ordered deterministically by address.
+func (valSet *defaultSet) lessValidator(a, b hotstuff.Validator) bool {
+	if valSet.less != nil {
+		if valSet.less(a, b) {
+			return true
+		}
+		if valSet.less(b, a) {
+			return false
+		}
+	}
+	return strings.Compare(a.String(), b.String()) < 0
+}
+
+// refresh rebuilds the address lookup map and the cached quorum parameters,
+// it should be called with the write lock held every time the validator list
+// is changed or reordered.
+func (valSet *defaultSet) refresh() {
+	valSet.indexes = make(map[common.Address]int, len(valSet.validators))
+	for i, v := range valSet.validators {
+		valSet.indexes[v.Address()] = i
+	}
+	n := len(valSet.validators)
+	valSet.f, valSet.q = faultBounds(n, valSet.faultModel)
+	atomic.StoreInt32(&valSet.size, int32(n))
+	valSet.metrics.update(n, valSet.f, valSet.q)
+}
+
+// faultBounds returns F and Q of a set of n validators under model.
+func faultBounds(n int, model hotstuff.FaultModel) (f, q int) {
+	if model == hotstuff.CFT {
+		return (n - 1) / 2, n/2 + 1
+	}
+	return (n - 1) / 3, (2*n + 2) / 3
+}
+
 func (valSet *defaultSet) GetProposer() hotstuff.Validator {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
 	return valSet.proposer
 }
 
 func (valSet *defaultSet) IsProposer(address common.Address) bool {
-	_, val := valSet.GetByAddress(address)
-	return reflect.DeepEqual(valSet.GetProposer(), val)
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.proposer != nil && valSet.proposer.Address() == address
+}
+
+// IsProposerForRound reports whether address is the proposer CalcProposer
+// would pick for round after lastProposer, independent of the stored proposer.
+// It lets blocks be verified out of order.
+func (valSet *defaultSet) IsProposerForRound(address common.Address, lastProposer common.Address, round uint64) bool {
+	proposer := valSet.ProposerForRound(lastProposer, round)
+	return proposer != nil && proposer.Address() == address
+}
+
+// IsProposerIndex reports whether the validator at index i is the proposer.
+func (valSet *defaultSet) IsProposerIndex(i uint64) bool {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	if valSet.proposer == nil || i >= uint64(len(valSet.validators)) {
+		return false
+	}
+	return valSet.validators[i].Address() == valSet.proposer.Address()
 }
 
+// ProposerIndex returns the index of the proposer, or -1 if there is none.
+func (valSet *defaultSet) ProposerIndex() int {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	if valSet.proposer == nil {
+		return -1
+	}
+	if idx, ok := valSet.indexes[valSet.proposer.Address()]; ok {
+		return idx
+	}
+	return -1
+}
+
+// CalcProposer runs the selector on a detached copy of the set, selectors call
+// back into the public accessors which take the read lock themselves, and
+// sync.RWMutex does not allow recursive read locking once a writer is queued.
+// The membership may change while the selector runs, see storeSelected.
 func (valSet *defaultSet) CalcProposer(lastProposer common.Address, round uint64) {
+	valSet.validatorMu.RLock()
+	view := valSet.detach()
+	valSet.validatorMu.RUnlock()
+
+	proposer := view.selectProposer(lastProposer, round)
+
+	valSet.validatorMu.Lock()
+	valSet.selectedFrom(lastProposer)
+	valSet.storeSelected(view, proposer)
+	valSet.validatorMu.Unlock()
+}
+
+// selectedFrom makes lastProposer the basis of the sticky advances, which are
+// dropped when it changes. It should be called with the write lock held.
+func (valSet *defaultSet) selectedFrom(lastProposer common.Address) {
+	if lastProposer != valSet.stickyBasis {
+		valSet.stickyBasis = lastProposer
+		valSet.stickyAdvance = 0
+	}
+}
+
+// storeSelected stores the proposer selected on view, it should be called
+// with the write lock held. If the selected validator was removed since view
+// was detached, its nearest successor in view which is still a member takes
+// over, or the first validator if none is.
+func (valSet *defaultSet) storeSelected(view *defaultSet, proposer hotstuff.Validator) {
+	if proposer == nil || len(valSet.validators) == 0 {
+		valSet.setProposer(nil)
+		return
+	}
+	if pos, ok := view.indexes[proposer.Address()]; ok {
+		for i := 0; i < len(view.validators); i++ {
+			next := view.validators[(pos+i)%len(view.validators)]
+			if idx, ok := valSet.indexes[next.Address()]; ok {
+				valSet.setProposer(valSet.validators[idx])
+				return
+			}
+		}
+	}
+	valSet.setProposer(valSet.validators[0])
+}
+
+// CalcProposerGlobal calculates the proposer like CalcProposer, at the round
+// counted from the start of the chain rather than of the epoch, see
+// GlobalRound. Rounds reset each epoch, so the schedule would otherwise start
+// over from the same index at every epoch.
+func (valSet *defaultSet) CalcProposerGlobal(lastProposer common.Address, epoch, round, roundsPerEpoch uint64) {
+	valSet.CalcProposer(lastProposer, GlobalRound(epoch, round, roundsPerEpoch))
+}
+
+// GlobalRound returns epoch*roundsPerEpoch + round. Like any round, it wraps
+// around past the largest uint64.
+func GlobalRound(epoch, round, roundsPerEpoch uint64) uint64 {
+	return epoch*roundsPerEpoch + round
+}
+
+// CalcProposerByRound calculates the proposer of round like CalcProposer, with
+// the last proposer stored by SetLastProposer.
+func (valSet *defaultSet) CalcProposerByRound(round uint64) {
+	valSet.CalcProposer(valSet.LastProposer(), round)
+}
+
+// SetLastProposer stores the proposer of the last block, so that callers need
+// not thread it through to every proposer calculation.
+func (valSet *defaultSet) SetLastProposer(addr common.Address) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+	valSet.lastProposer = addr
+}
+
+// SetGenesisProposer makes addr the proposer of round 0 when there is no last
+// proposer, i.e. of the first block of the chain or after a reset. Later
+// rounds carry on from it as if it were the last proposer of round 0.
+//
+// Without a genesis proposer, or if it is not a validator, the round alone
+// picks the proposer: round robin picks the validator at round % Size(), so
+// the first one at round 0.
+func (valSet *defaultSet) SetGenesisProposer(addr common.Address) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+	valSet.genesisProposer = addr
+}
+
+// GenesisProposer returns the address stored by SetGenesisProposer.
+func (valSet *defaultSet) GenesisProposer() common.Address {
 	valSet.validatorMu.RLock()
 	defer valSet.validatorMu.RUnlock()
-	valSet.proposer = valSet.selector(valSet, lastProposer, round)
+	return valSet.genesisProposer
 }
 
-func (valSet *defaultSet) CalcProposerByIndex(index uint64) {
-	if index > 1 {
-		index = (index - 1) % uint64(len(valSet.validators))
-	} else {
-		index = 0
+// LastProposer returns the address stored by SetLastProposer, the empty
+// address if none was stored.
+func (valSet *defaultSet) LastProposer() common.Address {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.lastProposer
+}
+
+// CalcProposerCtx calculates the proposer like CalcProposer. Round robin and
+// sticky selection are cheap and never fail, for the VRF policy the selector
+// runs in the background and the proposer is left untouched if ctx is done
+// first or if the selector does not return a member.
+func (valSet *defaultSet) CalcProposerCtx(ctx context.Context, lastProposer common.Address, round uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if valSet.Policy() != hotstuff.VRF {
+		valSet.CalcProposer(lastProposer, round)
+		return nil
+	}
+
+	valSet.validatorMu.RLock()
+	view := valSet.detach()
+	valSet.validatorMu.RUnlock()
+
+	if len(view.validators) == 0 {
+		valSet.validatorMu.Lock()
+		valSet.setProposer(nil)
+		valSet.validatorMu.Unlock()
+		return nil
+	}
+	done := make(chan hotstuff.Validator, 1)
+	go func() {
+		done <- view.selectFromGenesis(lastProposer, round)
+	}()
+
+	var proposer hotstuff.Validator
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case proposer = <-done:
 	}
-	valSet.proposer = valSet.validators[index]
+	if proposer == nil {
+		return ErrVRFSelection
+	}
+	if _, ok := view.indexes[proposer.Address()]; !ok {
+		return ErrVRFSelection
+	}
+
+	valSet.validatorMu.Lock()
+	valSet.selectedFrom(lastProposer)
+	valSet.storeSelected(view, proposer)
+	valSet.validatorMu.Unlock()
+	return nil
 }
 
-func calcSeed(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) uint64 {
-	offset := 0
-	if idx, val := valSet.GetByAddress(proposer); val != nil {
-		offset = idx
+// setProposer stores the proposer, it should be called with the write lock
+// held.
+func (valSet *defaultSet) setProposer(proposer hotstuff.Validator) {
+	changed := (valSet.proposer == nil) != (proposer == nil) ||
+		(proposer != nil && valSet.proposer.Address() != proposer.Address())
+	valSet.proposer = proposer
+	if proposer != nil {
+		valSet.history.push(proposer.Address())
+	}
+	if changed && valSet.metrics != nil {
+		valSet.metrics.proposerChanges.Inc(1)
 	}
-	return uint64(offset) + round
 }
 
-func emptyAddress(addr common.Address) bool {
-	return addr == common.Address{}
+// ProposerForRound returns the proposer CalcProposer would pick without
+// changing the stored one. If the selector returns nil or a non-member, the
+// validator at round % Size() is picked instead.
+func (valSet *defaultSet) ProposerForRound(lastProposer common.Address, round uint64) hotstuff.Validator {
+	valSet.validatorMu.RLock()
+	view := valSet.detach()
+	valSet.validatorMu.RUnlock()
+
+	return view.selectProposer(lastProposer, round)
 }
 
-func roundRobinSelector(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
-	if valSet.Size() == 0 {
+// NextProposer returns the validator which would propose the block after the
+// one of the current proposer, i.e. the pick of the selector at round 0 with
+// the current proposer as last proposer, which under round robin is also the
+// pick of the round following the current one. Without a current proposer the
+// stored last proposer is the basis. Nothing is changed, so that the network
+// layer can connect to the likely next leader in advance.
+func (valSet *defaultSet) NextProposer() hotstuff.Validator {
+	valSet.validatorMu.RLock()
+	view := valSet.detach()
+	basis := valSet.lastProposer
+	valSet.validatorMu.RUnlock()
+
+	if view.proposer != nil {
+		basis = view.proposer.Address()
+	}
+	return view.selectProposer(basis, 0)
+}
+
+// ProposerSchedule returns the proposers CalcProposer would pick for rounds
+// 0 to rounds-1 without changing the stored one.
+func (valSet *defaultSet) ProposerSchedule(lastProposer common.Address, rounds uint64) []common.Address {
+	valSet.validatorMu.RLock()
+	view := valSet.detach()
+	valSet.validatorMu.RUnlock()
+
+	if len(view.validators) == 0 {
 		return nil
 	}
-	seed := uint64(0)
-	if emptyAddress(proposer) {
-		seed = round
-	} else {
-		seed = calcSeed(valSet, proposer, round) + 1
+	schedule := make([]common.Address, rounds)
+	for round := uint64(0); round < rounds; round++ {
+		schedule[round] = view.selectProposer(lastProposer, round).Address()
 	}
-	pick := seed % uint64(valSet.Size())
-	return valSet.GetByIndex(pick)
+	return schedule
 }
 
-func stickySelector(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
-	if valSet.Size() == 0 {
+// selectProposer runs the selector and validates its pick, it must only be
+// called on a detached view.
+func (valSet *defaultSet) selectProposer(lastProposer common.Address, round uint64) hotstuff.Validator {
+	if len(valSet.validators) == 0 {
 		return nil
 	}
-	seed := uint64(0)
-	if emptyAddress(proposer) {
-		seed = round
-	} else {
-		seed = calcSeed(valSet, proposer, round)
+	proposer := valSet.selectFromGenesis(lastProposer, round)
+	if proposer != nil {
+		if idx, ok := valSet.indexes[proposer.Address()]; ok {
+			return valSet.validators[idx]
+		}
 	}
-	pick := seed % uint64(valSet.Size())
-	return valSet.GetByIndex(pick)
+	// a misbehaving selector must not leave a non-member as proposer
+	fallback := valSet.validators[round%uint64(len(valSet.validators))]
+	log.Warn("Invalid proposer selected, fall back to round robin", "proposer", proposer, "round", round, "fallback", fallback)
+	return fallback
 }
 
-// TODO: implement VRF
-func vrfSelector(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
-	return nil
+// selectFromGenesis runs the selector with the genesis proposer standing in
+// for an empty last proposer: the genesis proposer is picked at round 0 and
+// the selector continues from it at later rounds. It must only be called on a
+// detached view.
+func (valSet *defaultSet) selectFromGenesis(lastProposer common.Address, round uint64) hotstuff.Validator {
+	if lastProposer != valSet.stickyBasis {
+		// the advances were made from another last proposer
+		valSet.stickyAdvance = 0
+	}
+	if emptyAddress(lastProposer) {
+		if idx, ok := valSet.indexes[valSet.genesisProposer]; ok && !emptyAddress(valSet.genesisProposer) {
+			if round == 0 {
+				return nextActive(valSet, uint64(idx))
+			}
+			lastProposer, round = valSet.genesisProposer, round-1
+		}
+	}
+	return valSet.selector(valSet, lastProposer, round)
 }
 
-func (valSet *defaultSet) AddValidator(address common.Address) bool {
+// detach returns a copy of the set which shares no mutable state with the
+// original, it should be called with the read lock held.
+func (valSet *defaultSet) detach() *defaultSet {
+	validators := make(hotstuff.Validators, len(valSet.validators))
+	copy(validators, valSet.validators)
+	return &defaultSet{
+		validators: validators,
+		policy:     valSet.policy,
+		less:       valSet.less,
+		unsorted:   valSet.unsorted,
+		faultModel: valSet.faultModel,
+		// the map is replaced rather than mutated on membership change
+		indexes:   valSet.indexes,
+		f:         valSet.f,
+		q:         valSet.q,
+		size:      int32(len(validators)),
+		proposer:  valSet.proposer,
+		selector:  valSet.selector,
+		vrf:       valSet.vrf,
+		vrfOutput: valSet.vrfOutput,
+		seed:      valSet.seed,
+		epochSeed: valSet.epochSeed,
+		jailed:    valSet.jailed,
+
+		genesisProposer:  valSet.genesisProposer,
+		noRepeatProposer: valSet.noRepeatProposer,
+		stickyBasis:      valSet.stickyBasis,
+		stickyAdvance:    valSet.stickyAdvance,
+	}
+}
+
+// AdvanceProposer moves the proposer on to the next validator which is not
+// jailed. It is the explicit advance of the sticky policy: the advance is
+// recorded so that the sticky selector keeps honouring it at later rounds,
+// until CalcProposer is given another last proposer. The proposer moves the
+// same under every policy.
+func (valSet *defaultSet) AdvanceProposer() {
 	valSet.validatorMu.Lock()
 	defer valSet.validatorMu.Unlock()
-	for _, v := range valSet.validators {
-		if v.Address() == address {
-			return false
+
+	valSet.stickyAdvance++
+
+	if len(valSet.validators) == 0 {
+		valSet.setProposer(nil)
+		return
+	}
+	next := uint64(0)
+	if valSet.proposer != nil {
+		if idx, ok := valSet.indexes[valSet.proposer.Address()]; ok {
+			next = uint64(idx+1) % uint64(len(valSet.validators))
 		}
 	}
-	valSet.validators = append(valSet.validators, New(address))
-	// TODO: we may not need to re-sort it again
-	// sort validator
-	sort.Sort(valSet.validators)
-	return true
+	valSet.setProposer(nextActive(valSet.detach(), next))
 }
 
-func (valSet *defaultSet) RemoveValidator(address common.Address) bool {
+func (valSet *defaultSet) CalcProposerByIndex(index uint64) {
 	valSet.validatorMu.Lock()
 	defer valSet.validatorMu.Unlock()
-
-	for i, v := range valSet.validators {
-		if v.Address() == address {
-			valSet.validators = append(valSet.validators[:i], valSet.validators[i+1:]...)
-			return true
-		}
+	if len(valSet.validators) == 0 {
+		valSet.setProposer(nil)
+		return
 	}
-	return false
+	if index > 1 {
+		index = (index - 1) % uint64(len(valSet.validators))
+	} else {
+		index = 0
+	}
+	valSet.setProposer(valSet.validators[index])
 }
 
-func (valSet *defaultSet) Copy() hotstuff.ValidatorSet {
+// Seed returns the seed calcSeed derives from the last proposer and the
+// round, it lets external tooling check its proposer predictions against
+// consensus.
+func (valSet *defaultSet) Seed(lastProposer common.Address, round uint64) uint64 {
 	valSet.validatorMu.RLock()
-	defer valSet.validatorMu.RUnlock()
+	view := valSet.detach()
+	valSet.validatorMu.RUnlock()
 
-	addresses := make([]common.Address, 0, len(valSet.validators))
-	for _, v := range valSet.validators {
-		addresses = append(addresses, v.Address())
-	}
-	return NewSet(addresses, valSet.policy)
+	return calcSeed(view, lastProposer, round)
 }
 
-func (valSet *defaultSet) ParticipantsNumber(list []common.Address) int {
-	if list == nil || len(list) == 0 {
+// calcSeed returns the index of the last proposer plus the round, modulo the
+// size of the set. If the last proposer is no longer a validator, e.g. it was
+// just removed, its index is the position it would take in the current order,
+// which is the index of its nearest surviving successor.
+//
+// Both terms are reduced before the addition, so that a huge round from a peer
+// cannot wrap around uint64 and break the rotation: the seed of round+1 always
+// follows the seed of round.
+func calcSeed(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) uint64 {
+	size := uint64(valSet.Size())
+	if size == 0 {
 		return 0
 	}
-	size := 0
-	for _, v := range list {
-		if index, _ := valSet.GetByAddress(v); index < 0 {
-			continue
-		} else {
-			size += 1
-		}
+	offset := uint64(0)
+	if idx, val := valSet.GetByAddress(proposer); val != nil {
+		offset = uint64(idx)
+	} else {
+		offset = insertionIndex(valSet, proposer)
 	}
-	return size
+	return (offset%size + round%size) % size
 }
 
-func (valSet *defaultSet) CheckQuorum(committers []common.Address) error {
-	validators := valSet.Copy()
-	validSeal := 0
-	for _, addr := range committers {
-		if validators.RemoveValidator(addr) {
-			validSeal++
-			continue
+// insertionIndex returns the number of validators ordered before addr. The
+// weight of a non-member is unknown, so it is compared as a validator of
+// weight 1 by sets with a custom order.
+func insertionIndex(valSet hotstuff.ValidatorSet, addr common.Address) uint64 {
+	candidate := New(addr)
+	less := func(a, b hotstuff.Validator) bool {
+		return strings.Compare(a.String(), b.String()) < 0
+	}
+	if set, ok := valSet.(*defaultSet); ok {
+		// there is no telling where a non-member fits in a caller given order
+		if set.unsorted {
+			return 0
 		}
+		less = set.lessValidator
 	}
+	return uint64(sort.Search(valSet.Size(), func(i int) bool {
+		return !less(valSet.GetByIndex(uint64(i)), candidate)
+	}))
+}
 
-	// The length of validSeal should be larger than number of faulty node + 1
-	if validSeal <= validators.Q() {
-		return ErrInvalidParticipant
-	}
-	return nil
+func emptyAddress(addr common.Address) bool {
+	return addr == common.Address{}
 }
 
-func (valSet *defaultSet) F() int { return int(math.Ceil(float64(valSet.Size())/3)) - 1 }
+// NoRepeatReader is implemented by validator sets which may forbid the round
+// robin policy to pick the last proposer again.
+type NoRepeatReader interface {
+	NoRepeatProposer() bool
+}
 
-func (valSet *defaultSet) Q() int { return int(math.Ceil(float64(2*valSet.Size()) / 3)) }
+// SetNoRepeatProposer makes the round robin policy pick a validator other
+// than the last proposer whenever another one may propose. Otherwise the
+// rotation lands back on the last proposer every Size() rounds, which lets a
+// single validator propose consecutive blocks in small sets. Like any selector
+// setting, it must be the same on all nodes.
+func (valSet *defaultSet) SetNoRepeatProposer(noRepeat bool) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+	valSet.noRepeatProposer = noRepeat
+}
+
+// NoRepeatProposer returns the setting of SetNoRepeatProposer.
+func (valSet *defaultSet) NoRepeatProposer() bool {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.noRepeatProposer
+}
+
+func roundRobinSelector(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+	if valSet.Size() == 0 {
+		return nil
+	}
+	seed := uint64(0)
+	if emptyAddress(proposer) {
+		seed = round
+	} else {
+		seed = calcSeed(valSet, proposer, round)
+		// a removed last proposer has already been replaced at its index by
+		// its successor, which is thus the next in turn
+		if valSet.Contains(proposer) {
+			seed++
+		}
+	}
+	pick := seed % uint64(valSet.Size())
+	val := nextActive(valSet, pick)
+	if reader, ok := valSet.(NoRepeatReader); ok && reader.NoRepeatProposer() && val.Address() == proposer {
+		idx, _ := valSet.GetByAddress(proposer)
+		// nextActive wraps around, so it only comes back to the last
+		// proposer if no other validator may propose
+		val = nextActive(valSet, uint64(idx+1))
+	}
+	return val
+}
+
+// stickyRounds is the number of consecutive rounds a sticky leader keeps
+// before the next validator takes over, so that a crashed leader does not
+// stall the chain.
+const stickyRounds = 3
+
+// StickyAdvanceReader is implemented by validator sets which record the
+// explicit advances of the sticky policy, see AdvanceProposer.
+type StickyAdvanceReader interface {
+	StickyAdvance() uint64
+}
+
+// StickyAdvance returns the number of AdvanceProposer calls since the last
+// proposer given to CalcProposer changed.
+func (valSet *defaultSet) StickyAdvance() uint64 {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.stickyAdvance
+}
+
+// stickySelector keeps the last proposer for stickyRounds rounds, so that a
+// leader stays in charge across a few failed rounds, and moves on by one
+// validator every stickyRounds rounds after. Each explicit advance, see
+// AdvanceProposer, moves the pick on by one as well until the last proposer
+// changes. Jailed validators are skipped. Without a last proposer, the round
+// picks one, and a removed last proposer is replaced by its surviving
+// successor.
+func stickySelector(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+	size := uint64(valSet.Size())
+	if size == 0 {
+		return nil
+	}
+	advance := uint64(0)
+	if reader, ok := valSet.(StickyAdvanceReader); ok {
+		advance = reader.StickyAdvance() % size
+	}
+	seed := uint64(0)
+	if emptyAddress(proposer) {
+		seed = (round%size + advance) % size
+	} else {
+		seed = calcSeed(valSet, proposer, (round/stickyRounds)%size+advance)
+	}
+	return nextActive(valSet, seed)
+}
+
+// weightedRoundRobinSelector rotates over the voting power instead of the
+// validators: every unit of weight is a slot and a validator owns as many
+// consecutive slots as its weight. The rotation starts right after the slots
+// of the last proposer, so that over TotalWeight rounds every validator
+// proposes exactly as often as its weight.
+func weightedRoundRobinSelector(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+	size := valSet.Size()
+	if size == 0 {
+		return nil
+	}
+	// cumulative[i] is the total weight of validators [0, i]
+	cumulative := make([]uint64, size)
+	total := uint64(0)
+	for i := 0; i < size; i++ {
+		total += valSet.GetByIndex(uint64(i)).Weight()
+		cumulative[i] = total
+	}
+	if total == 0 {
+		return roundRobinSelector(valSet, proposer, round)
+	}
+
+	offset := uint64(0)
+	if !emptyAddress(proposer) {
+		if idx, val := valSet.GetByAddress(proposer); val != nil {
+			offset = cumulative[idx]
+		} else if idx := insertionIndex(valSet, proposer); idx > 0 {
+			offset = cumulative[idx-1]
+		}
+	}
+	slot := (offset%total + round%total) % total
+	pick := sort.Search(size, func(i int) bool { return cumulative[i] > slot })
+	return nextActive(valSet, uint64(pick))
+}
+
+// fixedSelector never rotates, the first validator which may propose does so
+// in every round whatever the last proposer. It is meant for reproducing
+// proposer dependent behaviour in tests.
+func fixedSelector(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+	if valSet.Size() == 0 {
+		return nil
+	}
+	return nextActive(valSet, 0)
+}
+
+// nextActive returns the first validator starting from index pick which may
+// propose, wrapping around the set. If none may, the first one is returned.
+func nextActive(valSet hotstuff.ValidatorSet, pick uint64) hotstuff.Validator {
+	size := uint64(valSet.Size())
+	for i := uint64(0); i < size; i++ {
+		val := valSet.GetByIndex((pick + i) % size)
+		if canPropose(valSet, val) {
+			return val
+		}
+	}
+	return valSet.GetByIndex(0)
+}
+
+// canPropose reports whether val is neither jailed nor vote-only.
+func canPropose(valSet hotstuff.ValidatorSet, val hotstuff.Validator) bool {
+	return val.ProposerEligible() && !valSet.IsJailed(val.Address())
+}
+
+func (valSet *defaultSet) AddValidator(address common.Address) bool {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+	if _, ok := valSet.indexes[address]; ok || emptyAddress(address) || valSet.full(0) {
+		return false
+	}
+	valSet.insert(New(address))
+	valSet.refresh()
+	valSet.notifyMembership(address, true)
+	return true
+}
+
+func (valSet *defaultSet) RemoveValidator(address common.Address) bool {
+	_, ok := valSet.RemoveValidatorGet(address)
+	return ok
+}
+
+// RemoveValidatorGet works as RemoveValidator but also returns the removed
+// validator, e.g. to refund its stake or log its weight.
+func (valSet *defaultSet) RemoveValidatorGet(address common.Address) (hotstuff.Validator, bool) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	i, ok := valSet.indexes[address]
+	if !ok {
+		return nil, false
+	}
+	return valSet.removeAt(i), true
+}
+
+// full reports whether the set reached its maximum size once the pending
+// validators are counted in.
+func (valSet *defaultSet) full(pending int) bool {
+	return valSet.maxSize > 0 && len(valSet.validators)+pending >= valSet.maxSize
+}
+
+// Rotator is implemented by validator sets whose order can be shifted
+// cyclically, see RotateBy.
+type Rotator interface {
+	RotateBy(offset uint64)
+}
+
+// RotateBy shifts the validator list cyclically, so that the validator at
+// index offset % Size() moves to index 0 and becomes the proposer, e.g. to
+// change the round 0 proposer every epoch without a full permutation. The
+// rotated list becomes the order of the set as for NewSetUnsorted: selectors
+// use it, validators added later are appended and copies keep it. Like the
+// validators themselves, the offset must be the same on all nodes.
+func (valSet *defaultSet) RotateBy(offset uint64) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	n := uint64(len(valSet.validators))
+	if n == 0 {
+		return
+	}
+	// reallocated rather than shifted in place, as it may be shared by views
+	validators := make(hotstuff.Validators, n)
+	for i := uint64(0); i < n; i++ {
+		validators[i] = valSet.validators[(i+offset%n)%n]
+	}
+	valSet.validators = validators
+	valSet.unsorted = true
+	valSet.refresh()
+	valSet.setProposer(validators[0])
+}
+
+// insert splices val into its sorted position. The slice is reallocated
+// rather than shifted in place, as it may be shared by detached views.
+func (valSet *defaultSet) insert(val hotstuff.Validator) {
+	pos := len(valSet.validators)
+	if !valSet.unsorted {
+		pos = sort.Search(len(valSet.validators), func(i int) bool {
+			return valSet.lessValidator(val, valSet.validators[i])
+		})
+	}
+	validators := make(hotstuff.Validators, len(valSet.validators)+1)
+	copy(validators, valSet.validators[:pos])
+	validators[pos] = val
+	copy(validators[pos+1:], valSet.validators[pos:])
+	valSet.validators = validators
+}
+
+// AddValidators adds every address which is not a validator yet and sorts the
+// set once, it returns the number of validators actually added.
+func (valSet *defaultSet) AddValidators(addrs []common.Address) int {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	added := make([]common.Address, 0, len(addrs))
+	seen := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		if _, ok := valSet.indexes[addr]; ok || emptyAddress(addr) {
+			continue
+		}
+		if _, ok := seen[addr]; ok {
+			continue
+		}
+		if valSet.full(len(added)) {
+			break
+		}
+		seen[addr] = struct{}{}
+		valSet.validators = append(valSet.validators, New(addr))
+		added = append(added, addr)
+	}
+	if len(added) == 0 {
+		return 0
+	}
+	valSet.sort()
+	valSet.refresh()
+	for _, addr := range added {
+		valSet.notifyMembership(addr, true)
+	}
+	return len(added)
+}
+
+// RemoveValidatorByIndex removes the validator at index i, the order of the
+// remaining validators is preserved.
+func (valSet *defaultSet) RemoveValidatorByIndex(i uint64) bool {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	if i >= uint64(len(valSet.validators)) {
+		return false
+	}
+	valSet.removeAt(int(i))
+	return true
+}
+
+// removeAt removes the validator at index i, it should be called with the
+// write lock held. If the validator was the proposer, its successor, which
+// now holds index i, takes over. The slice is reallocated rather than shifted
+// in place, as it may be shared by detached views. It returns the removed
+// validator.
+func (valSet *defaultSet) removeAt(i int) hotstuff.Validator {
+	removed := valSet.validators[i]
+	validators := make(hotstuff.Validators, 0, len(valSet.validators)-1)
+	validators = append(validators, valSet.validators[:i]...)
+	valSet.validators = append(validators, valSet.validators[i+1:]...)
+	valSet.refresh()
+
+	if valSet.proposer != nil && valSet.proposer.Address() == removed.Address() {
+		if len(valSet.validators) == 0 {
+			valSet.setProposer(nil)
+		} else {
+			valSet.setProposer(valSet.validators[i%len(valSet.validators)])
+		}
+	}
+	if _, ok := valSet.jailed[removed.Address()]; ok {
+		valSet.setJailed(removed.Address(), false)
+	}
+	valSet.notifyMembership(removed.Address(), false)
+	return removed
+}
+
+// RemoveValidators removes every given validator in a single pass, it returns
+// the number of validators actually removed.
+func (valSet *defaultSet) RemoveValidators(addrs []common.Address) int {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	drop := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		if _, ok := valSet.indexes[addr]; ok {
+			drop[addr] = struct{}{}
+		}
+	}
+	if len(drop) == 0 {
+		return 0
+	}
+	kept := make(hotstuff.Validators, 0, len(valSet.validators)-len(drop))
+	removed := make([]common.Address, 0, len(drop))
+	// the proposer is handed over to its first surviving successor
+	successor, proposerDropped := 0, false
+	for _, v := range valSet.validators {
+		if _, ok := drop[v.Address()]; ok {
+			removed = append(removed, v.Address())
+			if valSet.proposer != nil && v.Address() == valSet.proposer.Address() {
+				successor, proposerDropped = len(kept), true
+			}
+			continue
+		}
+		kept = append(kept, v)
+	}
+	valSet.validators = kept
+	valSet.refresh()
+	if proposerDropped {
+		if len(kept) == 0 {
+			valSet.setProposer(nil)
+		} else {
+			valSet.setProposer(kept[successor%len(kept)])
+		}
+	}
+	for _, addr := range removed {
+		if _, ok := valSet.jailed[addr]; ok {
+			valSet.setJailed(addr, false)
+		}
+		valSet.notifyMembership(addr, false)
+	}
+	return len(removed)
+}
+
+func (valSet *defaultSet) Copy() hotstuff.ValidatorSet {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.copy()
+}
+
+// DeepCopy returns a copy which, beyond the membership, configuration and
+// jailed validators carried by Copy, holds the very same state: proposer,
+// cached F and Q and the proposer history. It can be advanced
+// speculatively, e.g. by the block builder, without touching the original.
+// Metrics and membership subscriptions stay with the original.
+func (valSet *defaultSet) DeepCopy() hotstuff.ValidatorSet {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	cpy := valSet.copy()
+	// validators are never mutated, so the proposer can be shared even if
+	// it is no longer a member
+	cpy.proposer = valSet.proposer
+	cpy.f, cpy.q = valSet.f, valSet.q
+	cpy.history = valSet.history.clone()
+	return cpy
+}
+
+// copy implements Copy, it should be called with the read lock held.
+func (valSet *defaultSet) copy() *defaultSet {
+	validators := make(hotstuff.Validators, len(valSet.validators))
+	copy(validators, valSet.validators)
+	cpy := valSet.newLike(validators)
+	if valSet.proposer != nil {
+		if idx, ok := cpy.indexes[valSet.proposer.Address()]; ok {
+			cpy.proposer = cpy.validators[idx]
+		}
+	}
+	cpy.selector = valSet.selector
+	cpy.jailed = valSet.jailedMembers(cpy)
+	cpy.vrf = valSet.vrf
+	cpy.vrfOutput = common.CopyBytes(valSet.vrfOutput)
+	cpy.seed = valSet.seed
+	cpy.history = newProposerHistory(valSet.history.size())
+	cpy.epochSeed = valSet.epochSeed
+	cpy.lastProposer = valSet.lastProposer
+	cpy.stickyBasis, cpy.stickyAdvance = valSet.stickyBasis, valSet.stickyAdvance
+	cpy.epoch = valSet.epoch
+	cpy.added = valSet.added
+	cpy.removed = valSet.removed
+	return cpy
+}
+
+func (valSet *defaultSet) ParticipantsNumber(list []common.Address) int {
+	if list == nil || len(list) == 0 {
+		return 0
+	}
+	size := 0
+	for _, v := range list {
+		if valSet.Contains(v) {
+			size += 1
+		}
+	}
+	return size
+}
+
+// FilterMembers splits list into the addresses which are validators and the
+// ones which are not, both in the order of list. Duplicates are kept.
+func (valSet *defaultSet) FilterMembers(list []common.Address) (members, nonMembers []common.Address) {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	for _, addr := range list {
+		if _, ok := valSet.indexes[addr]; ok {
+			members = append(members, addr)
+		} else {
+			nonMembers = append(nonMembers, addr)
+		}
+	}
+	return members, nonMembers
+}
+
+// AbsentMembers returns the validators which are not among committers, e.g.
+// to track the uptime of the signers of a block, sorted by address. It is the
+// complement of the count of the quorum checks: duplicated and non-member
+// committers are ignored.
+func (valSet *defaultSet) AbsentMembers(committers []common.Address) []common.Address {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	signed := make(map[common.Address]struct{}, len(committers))
+	for _, addr := range committers {
+		signed[addr] = struct{}{}
+	}
+	absent := make([]common.Address, 0, len(valSet.validators))
+	for _, v := range valSet.validators {
+		if _, ok := signed[v.Address()]; !ok {
+			absent = append(absent, v.Address())
+		}
+	}
+	sortAddresses(absent)
+	return absent
+}
+
+// CheckQuorum checks that the distinct members among committers reach the
+// quorum, see QuorumReached for the exact threshold. Non-members and
+// duplicates are ignored.
+func (valSet *defaultSet) CheckQuorum(committers []common.Address) error {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	if len(valSet.validators) == 0 {
+		return ErrEmptySet
+	}
+	validSeal, _, _ := valSet.countCommitters(committers, false)
+
+	if !valSet.quorumReached(validSeal) {
+		return ErrBelowQuorum
+	}
+	return nil
+}
+
+// CheckQuorumStrict works as CheckQuorum but fails with ErrNonMember or
+// ErrDuplicateCommitter naming the first committer which is not a validator of
+// the set or which is listed twice.
+func (valSet *defaultSet) CheckQuorumStrict(committers []common.Address) error {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	if len(valSet.validators) == 0 {
+		return ErrEmptySet
+	}
+	if len(committers) > len(valSet.validators) {
+		return fmt.Errorf("%w: have %d, want at most %d", ErrTooManyCommitters, len(committers), len(valSet.validators))
+	}
+	validSeal, _, err := valSet.countCommitters(committers, true)
+	if err != nil {
+		return err
+	}
+	if !valSet.quorumReached(validSeal) {
+		return ErrBelowQuorum
+	}
+	return nil
+}
+
+// CheckWeightedQuorum checks that the distinct committers hold more than 2/3
+// of the total voting power, or more than half of it under CFT.
+func (valSet *defaultSet) CheckWeightedQuorum(committers []common.Address) error {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	if len(valSet.validators) == 0 {
+		return ErrEmptySet
+	}
+	_, weight, _ := valSet.countCommitters(committers, false)
+	if !valSet.weightedQuorum(weight) {
+		return ErrBelowQuorum
+	}
+	return nil
+}
+
+// weightedQuorum reports whether weight is a quorum of the voting power under
+// the fault model, it should be called with the read lock held.
+func (valSet *defaultSet) weightedQuorum(weight uint64) bool {
+	total := valSet.totalWeight()
+	if valSet.faultModel == hotstuff.CFT {
+		// weight never exceeds the total, so this is weight > total/2
+		return weight > total-weight
+	}
+	return exceedsTwoThirds(weight, total)
+}
+
+// exceedsTwoThirds reports whether part > 2/3 * total, the products are
+// computed on 128 bits so that large stakes can not overflow.
+func exceedsTwoThirds(part, total uint64) bool {
+	hi1, lo1 := bits.Mul64(part, 3)
+	hi2, lo2 := bits.Mul64(total, 2)
+	return hi1 > hi2 || (hi1 == hi2 && lo1 > lo2)
+}
+
+// countCommitters returns the number and the voting power of the distinct
+// validators among committers, in strict mode the first non-member or
+// duplicate aborts the count. It should be called with the read lock held.
+func (valSet *defaultSet) countCommitters(committers []common.Address, strict bool) (int, uint64, error) {
+	// mark committed validators on a bitmap so that each counts only once,
+	// the stack buffer covers sets of up to 256 validators without allocating
+	var buf [4]uint64
+	seen := buf[:]
+	if words := (len(valSet.validators) + 63) / 64; words > len(buf) {
+		seen = make([]uint64, words)
+	}
+	count, weight := 0, uint64(0)
+	for _, addr := range committers {
+		idx, ok := valSet.indexes[addr]
+		if !ok {
+			if strict {
+				return count, weight, fmt.Errorf("%w: %s", ErrNonMember, addr.Hex())
+			}
+			continue
+		}
+		if seen[idx/64]&(1<<(uint(idx)%64)) != 0 {
+			if strict {
+				return count, weight, fmt.Errorf("%w: %s", ErrDuplicateCommitter, addr.Hex())
+			}
+			continue
+		}
+		seen[idx/64] |= 1 << (uint(idx) % 64)
+		count++
+		weight += valSet.validators[idx].Weight()
+	}
+	return count, weight, nil
+}
+
+// F returns the number of byzantine validators the set tolerates, the classic
+// bound f = (n-1)/3 which keeps n >= 3f+1. Under CFT it is the number of
+// crashed validators tolerated, f = (n-1)/2.
+func (valSet *defaultSet) F() int {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.f
+}
+
+// Q returns ceil(2n/3). It equals 2f+1 when n = 3f+1 and is larger otherwise,
+// which keeps any two quorums intersecting in at least f+1 validators, so at
+// least one honest validator is shared. Under CFT it is the majority n/2+1.
+func (valSet *defaultSet) Q() int {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.q
+}
+
+// QuorumReached reports whether count distinct committers form a quorum, i.e.
+// count >= QuorumSize(): ceil(2n/3) under BFT, which for n=4 is 3 committers,
+// or n/2+1 under CFT. The threshold is inclusive, it is not count > Q. Every
+// unweighted quorum check goes through it.
+func (valSet *defaultSet) QuorumReached(count int) bool {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.quorumReached(count)
+}
+
+// quorumReached works as QuorumReached, it should be called with the read
+// lock held.
+func (valSet *defaultSet) quorumReached(count int) bool {
+	return count >= valSet.quorumSize()
+}
+
+// QuorumSize returns the number of distinct committers needed to verify
+// aggregated commit signatures. It equals Q, so ceil(2n/3) under BFT, which is
+// 2f+1 when n = 3f+1 and larger otherwise, and the majority n/2+1 under CFT.
+func (valSet *defaultSet) QuorumSize() int {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.quorumSize()
+}
+
+// MinQuorumCommitters returns a smallest list of committers forming a quorum,
+// e.g. to build a minimal valid QC in tests. It holds the first QuorumSize()
+// validators, which pass CheckQuorum while any fewer fail it. If any validator
+// weighs other than 1, it instead holds the heaviest validators, lower indexes
+// first among equal weights, until they pass CheckWeightedQuorum. Either way
+// the committers are listed in validator order.
+func (valSet *defaultSet) MinQuorumCommitters() []common.Address {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	if len(valSet.validators) == 0 {
+		return []common.Address{}
+	}
+	if !valSet.weighted() {
+		committers := make([]common.Address, valSet.quorumSize())
+		for i := range committers {
+			committers[i] = valSet.validators[i].Address()
+		}
+		return committers
+	}
+	byWeight := make([]int, len(valSet.validators))
+	for i := range byWeight {
+		byWeight[i] = i
+	}
+	sort.SliceStable(byWeight, func(i, j int) bool {
+		return valSet.validators[byWeight[i]].Weight() > valSet.validators[byWeight[j]].Weight()
+	})
+	picked, weight := make([]int, 0, len(byWeight)), uint64(0)
+	for _, idx := range byWeight {
+		if valSet.weightedQuorum(weight) {
+			break
+		}
+		picked = append(picked, idx)
+		weight += valSet.validators[idx].Weight()
+	}
+	sort.Ints(picked)
+	committers := make([]common.Address, len(picked))
+	for i, idx := range picked {
+		committers[i] = valSet.validators[idx].Address()
+	}
+	return committers
+}
+
+// Params returns the size and fault tolerance parameters under a single read
+// lock, so that they are consistent with each other.
+func (valSet *defaultSet) Params() hotstuff.ValidatorSetParams {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	return hotstuff.ValidatorSetParams{
+		Size:        len(valSet.validators),
+		F:           valSet.f,
+		Q:           valSet.q,
+		QuorumSize:  valSet.quorumSize(),
+		TotalWeight: valSet.totalWeight(),
+	}
+}
+
+// minBFTSize is the smallest set which tolerates a faulty validator, n = 3f+1
+// with f = 1.
+const minBFTSize = 4
+
+// minCFTSize is the smallest set which tolerates a crashed validator, n = 2f+1
+// with f = 1.
+const minCFTSize = 3
+
+// CanReachQuorum reports whether the validators are able to form a quorum at
+// all, which is not the case for an empty set.
+func (valSet *defaultSet) CanReachQuorum() bool {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return len(valSet.validators) > 0 && valSet.quorumSize() <= len(valSet.validators)
+}
+
+// HealthCheck returns an error describing why the set is unfit for consensus
+// under its fault model, i.e. it cannot form a quorum or tolerate a single
+// faulty validator, and nil otherwise.
+func (valSet *defaultSet) HealthCheck() error {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	size, minSize := len(valSet.validators), minBFTSize
+	if valSet.faultModel == hotstuff.CFT {
+		minSize = minCFTSize
+	}
+	if size < minSize {
+		return fmt.Errorf("%w: have %d, want at least %d to tolerate f=1", ErrTooFewValidators, size, minSize)
+	}
+	return nil
+}
+
+// quorumSize returns the number of distinct committers a quorum needs, which
+// is Q under either fault model. Under BFT 2f+1 alone is not enough unless
+// n = 3f+1: with n=3 it is a single committer, so two disjoint quorums could
+// commit conflicting blocks without any faulty validator. Q = ceil(2n/3) is
+// at least 2f+1 and keeps any two quorums sharing an honest validator. A
+// single validator set still reaches quorum with its lone committer, which
+// single node dev chains rely on.
+func (valSet *defaultSet) quorumSize() int {
+	return valSet.q
+}
+
+// checkTotalWeight fails with ErrWeightOverflow if the voting power of the
+// validators does not fit in a uint64. Weighted sets are checked when they are
+// created, so that totalWeight and the committed weight never wrap around.
+func checkTotalWeight(validators []hotstuff.Validator) error {
+	total := uint64(0)
+	for _, v := range validators {
+		var carry uint64
+		if total, carry = bits.Add64(total, v.Weight(), 0); carry != 0 {
+			return ErrWeightOverflow
+		}
+	}
+	return nil
+}
+
+// totalWeight sums the voting power of all validators, it should be called
+// with the read lock held.
+func (valSet *defaultSet) totalWeight() uint64 {
+	total := uint64(0)
+	for _, v := range valSet.validators {
+		total += v.Weight()
+	}
+	return total
+}
+
+// weighted reports whether any validator weighs other than 1, it should be
+// called with the read lock held.
+func (valSet *defaultSet) weighted() bool {
+	for _, v := range valSet.validators {
+		if v.Weight() != 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// TotalWeight returns the sum of the voting power of all validators, for an
+// unweighted set every validator weighs 1 and it equals Size.
+func (valSet *defaultSet) TotalWeight() uint64 {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.totalWeight()
+}
+
+// ValidateTotalWeight checks that the voting power of the set sums up to
+// expected, e.g. the total stake recorded on chain for the epoch, so that a
+// weight dropped while building the set is caught.
+func (valSet *defaultSet) ValidateTotalWeight(expected uint64) error {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	if total := valSet.totalWeight(); total != expected {
+		return fmt.Errorf("%w: have %d, want %d", ErrWeightMismatch, total, expected)
+	}
+	return nil
+}
+
+// WeightedF returns the maximum voting power which may be faulty, it is the
+// weighted counterpart of F.
+func (valSet *defaultSet) WeightedF() uint64 {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	total := valSet.totalWeight()
+	if total == 0 {
+		return 0
+	}
+	if valSet.faultModel == hotstuff.CFT {
+		return (total - 1) / 2
+	}
+	return (total+2)/3 - 1
+}
+
+// WeightedQ returns the minimum voting power of a quorum, i.e. the least
+// committed weight passing CheckWeightedQuorum: floor(2T/3)+1 of the total T,
+// or floor(T/2)+1 under CFT. It is 0 for an empty set.
+func (valSet *defaultSet) WeightedQ() uint64 {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	total := valSet.totalWeight()
+	if total == 0 {
+		return 0
+	}
+	if valSet.faultModel == hotstuff.CFT {
+		return total/2 + 1
+	}
+	// 2T is computed on 128 bits, the quotient fits in 64 as 2T/3 < T
+	hi, lo := bits.Mul64(total, 2)
+	twoThirds, _ := bits.Div64(hi, lo, 3)
+	return twoThirds + 1
+}
+
+func (valSet *defaultSet) String() string {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	proposer := "nil"
+	if valSet.proposer != nil {
+		proposer = valSet.proposer.String()
+	}
+	members := make([]string, len(valSet.validators))
+	for i, v := range valSet.validators {
+		members[i] = v.String()
+	}
+	return fmt.Sprintf("ValSet{size=%d, policy=%s, proposer=%s, members=[%s]}",
+		len(valSet.validators), valSet.policy, proposer, strings.Join(members, ", "))
+}
 
 func (valSet *defaultSet) Policy() hotstuff.SelectProposerPolicy { return valSet.policy }
 
+func (valSet *defaultSet) FaultModel() hotstuff.FaultModel { return valSet.faultModel }
+
+// Cmp reports whether src has the same members, regardless of their order,
+// the policy or any duplicated entry.
 func (valSet *defaultSet) Cmp(src hotstuff.ValidatorSet) bool {
-	n := valSet.ParticipantsNumber(src.AddressList())
-	if n != valSet.Size() || n != src.Size() {
+	return valSet.EqualUnordered(src.AddressList())
+}
+
+// EqualUnordered reports whether addrs names exactly the members of the set,
+// regardless of their order or any duplicated entry. It lets a light client
+// compare the address lists received from several peers.
+func (valSet *defaultSet) EqualUnordered(addrs []common.Address) bool {
+	have := uniqueSortedAddresses(valSet.AddressList())
+	want := uniqueSortedAddresses(addrs)
+	if len(have) != len(want) {
 		return false
 	}
+	for i := range have {
+		if have[i] != want[i] {
+			return false
+		}
+	}
 	return true
 }
+
+// Equal reports whether src holds the same validators in the same order under
+// the same policy. Unlike Cmp, which only checks membership, it also catches
+// sets which are configured differently.
+func (valSet *defaultSet) Equal(src hotstuff.ValidatorSet) bool {
+	equal, _, _ := valSet.Compare(src)
+	return equal
+}
+
+// Comparer is implemented by validator sets which tell where and why they
+// differ from another set.
+type Comparer interface {
+	Compare(src hotstuff.ValidatorSet) (equal bool, firstDiffIndex int, reason string)
+}
+
+// Compare works as Equal but also tells where and why the sets differ, e.g. to
+// find out why two nodes computed different proposers. firstDiffIndex is the
+// first index holding different validators, or the length of the shorter list
+// if one is a prefix of the other, and -1 if the sets are equal or only the
+// policy differs.
+func (valSet *defaultSet) Compare(src hotstuff.ValidatorSet) (equal bool, firstDiffIndex int, reason string) {
+	return compareSets(valSet, src)
+}
+
+// compareSets implements Compare on top of the ValidatorSet interface, so that
+// wrapping sets share it.
+func compareSets(valSet, src hotstuff.ValidatorSet) (equal bool, firstDiffIndex int, reason string) {
+	if have, want := valSet.Policy(), src.Policy(); have != want {
+		return false, -1, fmt.Sprintf("policy mismatch: have %v, want %v", have, want)
+	}
+	have, want := valSet.AddressList(), src.AddressList()
+	for i := 0; i < len(have) && i < len(want); i++ {
+		if have[i] != want[i] {
+			return false, i, fmt.Sprintf("address mismatch at index %d: have %s, want %s", i, have[i].Hex(), want[i].Hex())
+		}
+	}
+	if len(have) != len(want) {
+		diff := len(have)
+		if len(want) < diff {
+			diff = len(want)
+		}
+		return false, diff, fmt.Sprintf("size mismatch: have %d, want %d", len(have), len(want))
+	}
+	return true, -1, ""
+}
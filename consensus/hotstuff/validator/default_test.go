@@ -19,10 +19,17 @@
 package validator
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/hotstuff"
@@ -156,43 +163,52 @@ func testEmptyValSet(t *testing.T) {
 	if valSet == nil {
 		t.Errorf("validator set should not be nil")
 	}
+	// proposer calculation must not panic on an empty set
+	valSet.CalcProposerByIndex(3)
+	if val := valSet.GetProposer(); val != nil {
+		t.Errorf("proposer mismatch: have %v, want nil", val)
+	}
+	valSet.CalcProposer(common.HexToAddress("0x1"), 3)
+	if val := valSet.GetProposer(); val != nil {
+		t.Errorf("proposer mismatch: have %v, want nil", val)
+	}
 }
 
 func testAddAndRemoveValidator(t *testing.T) {
 	valSet := NewSet(ExtractValidators([]byte{}), hotstuff.RoundRobin)
-	if !valSet.AddValidator(common.HexToAddress("0x2")) {
+	if !valSet.AddValidator(common.HexToAddress("0x3")) {
 		t.Error("the validator should be added")
 	}
-	if valSet.AddValidator(common.HexToAddress("0x2")) {
+	if valSet.AddValidator(common.HexToAddress("0x3")) {
 		t.Error("the existing validator should not be added")
 	}
+	valSet.AddValidator(common.HexToAddress("0x2"))
 	valSet.AddValidator(common.HexToAddress("0x1"))
-	valSet.AddValidator(common.HexToAddress("0x0"))
 	if len(valSet.List()) != 3 {
 		t.Error("the size of validator set should be 3")
 	}
 
 	for i, v := range valSet.List() {
-		expected := common.HexToAddress(fmt.Sprintf("0x%d", i))
+		expected := common.HexToAddress(fmt.Sprintf("0x%d", i+1))
 		if v.Address() != expected {
 			t.Errorf("the order of validators is wrong: have %v, want %v", v.Address().Hex(), expected.Hex())
 		}
 	}
 
-	if !valSet.RemoveValidator(common.HexToAddress("0x2")) {
+	if !valSet.RemoveValidator(common.HexToAddress("0x3")) {
 		t.Error("the validator should be removed")
 	}
-	if valSet.RemoveValidator(common.HexToAddress("0x2")) {
+	if valSet.RemoveValidator(common.HexToAddress("0x3")) {
 		t.Error("the non-existing validator should not be removed")
 	}
 	if len(valSet.List()) != 2 {
 		t.Error("the size of validator set should be 2")
 	}
-	valSet.RemoveValidator(common.HexToAddress("0x1"))
+	valSet.RemoveValidator(common.HexToAddress("0x2"))
 	if len(valSet.List()) != 1 {
 		t.Error("the size of validator set should be 1")
 	}
-	valSet.RemoveValidator(common.HexToAddress("0x0"))
+	valSet.RemoveValidator(common.HexToAddress("0x1"))
 	if len(valSet.List()) != 0 {
 		t.Error("the size of validator set should be 0")
 	}
@@ -219,7 +235,19 @@ func testStickyProposer(t *testing.T) {
 		t.Errorf("proposer mismatch: have %v, want %v", val, val1)
 	}
 
-	valSet.CalcProposer(lastproposer, uint64(1))
+	// the proposer sticks across failed rounds
+	for _, round := range []uint64{1, 2} {
+		valSet.CalcProposer(lastproposer, round)
+		if val := valSet.GetProposer(); !reflect.DeepEqual(val, val1) {
+			t.Errorf("proposer mismatch: have %v, want %v", val, val1)
+		}
+	}
+	// until it is explicitly advanced
+	valSet.AdvanceProposer()
+	if val := valSet.GetProposer(); !reflect.DeepEqual(val, val2) {
+		t.Errorf("proposer mismatch: have %v, want %v", val, val2)
+	}
+	valSet.CalcProposer(valSet.GetProposer().Address(), uint64(1))
 	if val := valSet.GetProposer(); !reflect.DeepEqual(val, val2) {
 		t.Errorf("proposer mismatch: have %v, want %v", val, val2)
 	}
@@ -248,3 +276,1600 @@ func TestFAndQ(t *testing.T) {
 	assert.Equal(t, 5, quorumSize)
 	t.Logf("faulty size %d, quorum size %d", faultySize, quorumSize)
 }
+
+func TestGetByAddressIndexes(t *testing.T) {
+	addrs := testAddresses(11)
+	valSet := newDefaultSet(addrs[1:], hotstuff.RoundRobin)
+	assertIndexes := func() {
+		for i, v := range valSet.List() {
+			idx, val := valSet.GetByAddress(v.Address())
+			assert.Equal(t, i, idx)
+			assert.Equal(t, v, val)
+		}
+		assert.Equal(t, len(valSet.List()), len(valSet.indexes))
+	}
+	assertIndexes()
+
+	// lower address is inserted in front, all indexes must shift
+	assert.True(t, valSet.AddValidator(addrs[0]))
+	assertIndexes()
+
+	removed := valSet.GetByIndex(3).Address()
+	assert.True(t, valSet.RemoveValidator(removed))
+	assertIndexes()
+	idx, val := valSet.GetByAddress(removed)
+	assert.Equal(t, -1, idx)
+	assert.Nil(t, val)
+}
+
+func TestZeroAddress(t *testing.T) {
+	addrs := testAddresses(3)
+	withZero := append([]common.Address{{}}, addrs...)
+
+	if _, err := NewSetSafe(withZero, hotstuff.RoundRobin); !errors.Is(err, ErrInvalidParticipant) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidParticipant)
+	}
+	if _, err := NewWeightedSet(withZero, []uint64{1, 1, 1, 1}, hotstuff.RoundRobin); !errors.Is(err, ErrZeroAddress) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrZeroAddress)
+	}
+	if _, err := NewBLSSet(withZero, make([][]byte, 4), hotstuff.RoundRobin); !errors.Is(err, ErrZeroAddress) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrZeroAddress)
+	}
+
+	// the unchecked constructors drop it, nil validators are dropped as well
+	valSet := NewSet(withZero, hotstuff.RoundRobin)
+	assert.Equal(t, addrs, valSet.AddressList())
+	valSet = newDefaultSetWithValidators(hotstuff.Validators{nil, New(addrs[1]), New(common.Address{})}, hotstuff.RoundRobin)
+	assert.Equal(t, addrs[1:2], valSet.AddressList())
+	for _, val := range valSet.List() {
+		assert.NotNil(t, val)
+	}
+
+	assert.False(t, valSet.AddValidator(common.Address{}))
+	assert.Equal(t, 1, valSet.AddValidators(withZero[:2]))
+	assert.False(t, valSet.Contains(common.Address{}))
+
+}
+
+func TestCalcProposerConcurrentWriter(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(4), hotstuff.RoundRobin)
+
+	stop := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			addr := common.BigToAddress(big.NewInt(int64(1000 + i%50)))
+			if !valSet.AddValidator(addr) {
+				valSet.RemoveValidator(addr)
+			}
+		}
+	}()
+	defer close(stop)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		last := common.Address{}
+		for round := uint64(0); round < 10000; round++ {
+			valSet.CalcProposer(last, round)
+			last = valSet.GetProposer().Address()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("CalcProposer deadlocked with a concurrent writer")
+	}
+}
+
+func TestCalcProposerRemovedWhileSelecting(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.VRF)
+
+	// the selector runs unlocked, the validators it picks may be removed
+	// before the pick is stored, their surviving successor then takes over
+	removeWhileSelecting := func(removed ...common.Address) {
+		valSet.SetSelector(func(view hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+			for _, addr := range removed {
+				valSet.RemoveValidator(addr)
+			}
+			_, val := view.GetByAddress(removed[0])
+			return val
+		})
+	}
+	removeWhileSelecting(addrs[1])
+	valSet.CalcProposer(addrs[0], 1)
+	assert.Equal(t, addrs[2], valSet.GetProposer().Address())
+
+	removeWhileSelecting(addrs[3], addrs[0])
+	assert.NoError(t, valSet.CalcProposerCtx(context.Background(), addrs[2], 1))
+	assert.Equal(t, addrs[2], valSet.GetProposer().Address())
+	assert.True(t, valSet.Contains(valSet.GetProposer().Address()))
+}
+
+func TestWeightedSet(t *testing.T) {
+	addrs := testAddresses(4)
+	if _, err := NewWeightedSet(addrs, []uint64{1, 2, 3}, hotstuff.RoundRobin); err != ErrInvalidParticipant {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidParticipant)
+	}
+	if _, err := NewWeightedSet(addrs, []uint64{1, 2, 0, 4}, hotstuff.RoundRobin); err != ErrInvalidParticipant {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidParticipant)
+	}
+	// the total would wrap around to 0, so that either validator alone passed
+	if _, err := NewWeightedSet(addrs[:2], []uint64{1 << 63, 1 << 63}, hotstuff.RoundRobin); err != ErrWeightOverflow {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrWeightOverflow)
+	}
+	if _, err := NewWeightedSet(addrs[:2], []uint64{1 << 63, 1<<63 - 1}, hotstuff.RoundRobin); err != nil {
+		t.Errorf("failed to create set of maximum weight: %v", err)
+	}
+
+	valSet, err := NewWeightedSet(addrs, []uint64{10, 20, 30, 40}, hotstuff.RoundRobin)
+	if err != nil {
+		t.Fatalf("failed to create weighted set: %v", err)
+	}
+	_, val := valSet.GetByAddress(addrs[2])
+	assert.Equal(t, uint64(30), val.Weight())
+	assert.Equal(t, uint64(33), valSet.WeightedF())
+	assert.Equal(t, uint64(67), valSet.WeightedQ())
+	assert.Equal(t, uint64(100), valSet.TotalWeight())
+
+	// weights survive a copy
+	_, val = valSet.Copy().GetByAddress(addrs[3])
+	assert.Equal(t, uint64(40), val.Weight())
+
+	// unweighted sets behave as if every validator had weight 1
+	unweighted := newDefaultSet(testAddresses(7), hotstuff.RoundRobin)
+	assert.Equal(t, uint64(unweighted.F()), unweighted.WeightedF())
+	assert.Equal(t, uint64(unweighted.Q()), unweighted.WeightedQ())
+	assert.Equal(t, uint64(unweighted.Size()), unweighted.TotalWeight())
+}
+
+func TestWeightedQ(t *testing.T) {
+	testCases := []struct {
+		weights []uint64
+		model   hotstuff.FaultModel
+		q       uint64
+	}{
+		{[]uint64{1, 2}, hotstuff.BFT, 3},
+		{[]uint64{2, 2}, hotstuff.BFT, 3},
+		{[]uint64{1, 2, 3}, hotstuff.BFT, 5},
+		{[]uint64{1 << 63, 1<<63 - 1}, hotstuff.BFT, 12297829382473034411},
+		{[]uint64{1, 2}, hotstuff.CFT, 2},
+		{[]uint64{2, 2}, hotstuff.CFT, 3},
+	}
+	for i, test := range testCases {
+		builder := NewBuilder().WithFaultModel(test.model)
+		for j, weight := range test.weights {
+			builder.AddWeighted(testAddresses(len(test.weights))[j], weight)
+		}
+		valSet, err := builder.Build()
+		if err != nil {
+			t.Fatalf("test %d: failed to create set: %v", i, err)
+		}
+		if q := valSet.WeightedQ(); q != test.q {
+			t.Errorf("test %d: weighted quorum mismatch: have %d, want %d", i, q, test.q)
+		}
+	}
+	assert.Equal(t, uint64(0), newDefaultSet(nil, hotstuff.RoundRobin).WeightedQ())
+
+	// WeightedQ is exactly the boundary of CheckWeightedQuorum
+	addrs := testAddresses(4)
+	for last := uint64(1); last <= 30; last++ {
+		valSet, _ := NewWeightedSet(addrs, []uint64{1, 1, 1, last}, hotstuff.RoundRobin)
+		q := valSet.WeightedQ()
+		for _, committers := range [][]common.Address{addrs[:1], addrs[:2], addrs[:3], addrs[2:], addrs[3:], addrs} {
+			weight := uint64(0)
+			for _, addr := range committers {
+				_, val := valSet.GetByAddress(addr)
+				weight += val.Weight()
+			}
+			pass := valSet.CheckWeightedQuorum(committers) == nil
+			assert.Equal(t, weight >= q, pass, "weights 1, 1, 1, %d: committed %d, quorum %d", last, weight, q)
+		}
+	}
+}
+
+func TestValidateTotalWeight(t *testing.T) {
+	addrs := testAddresses(4)
+	// the on-chain stakes are 10, 20, 30 and 40, but one weight was mistyped
+	valSet, err := NewWeightedSet(addrs, []uint64{10, 20, 3, 40}, hotstuff.RoundRobin)
+	if err != nil {
+		t.Fatalf("failed to create weighted set: %v", err)
+	}
+	if err := valSet.ValidateTotalWeight(100); !errors.Is(err, ErrWeightMismatch) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrWeightMismatch)
+	}
+	assert.NoError(t, valSet.ValidateTotalWeight(73))
+
+	// a dropped validator is caught as well
+	valSet.RemoveValidator(addrs[0])
+	assert.ErrorIs(t, valSet.ValidateTotalWeight(73), ErrWeightMismatch)
+
+	assert.NoError(t, newDefaultSet(addrs, hotstuff.RoundRobin).ValidateTotalWeight(4))
+	assert.NoError(t, newDefaultSet(nil, hotstuff.RoundRobin).ValidateTotalWeight(0))
+}
+
+func TestFAndQTable(t *testing.T) {
+	for n := 1; n <= 30; n++ {
+		vs := newDefaultSet(testAddresses(n), hotstuff.RoundRobin)
+		f, q := vs.F(), vs.Q()
+		if want := (n - 1) / 3; f != want {
+			t.Errorf("n=%d: faulty size mismatch: have %d, want %d", n, f, want)
+		}
+		if n%3 == 1 && q != 2*f+1 {
+			t.Errorf("n=%d: quorum size mismatch: have %d, want %d", n, q, 2*f+1)
+		}
+		if q < 2*f+1 || q > n {
+			t.Errorf("n=%d: quorum size %d out of range [%d, %d]", n, q, 2*f+1, n)
+		}
+		// two quorums must share at least one honest validator
+		if 2*q-n <= f {
+			t.Errorf("n=%d: quorums of size %d intersect in only %d validators", n, q, 2*q-n)
+		}
+	}
+}
+
+func TestQuorumSize(t *testing.T) {
+	// 2f+1 would let a single committer of 2 or 3 validators, or 3 of 5 or 6,
+	// form a quorum, so that two disjoint quorums could exist
+	testCases := []struct {
+		n, qsize int
+	}{
+		{1, 1}, {2, 2}, {3, 2}, {4, 3}, {5, 4}, {6, 4}, {7, 5},
+	}
+	for _, test := range testCases {
+		vs := newDefaultSet(testAddresses(test.n), hotstuff.RoundRobin)
+		if have := vs.QuorumSize(); have != test.qsize {
+			t.Errorf("n=%d: quorum size mismatch: have %d, want %d", test.n, have, test.qsize)
+		}
+		addrs := vs.AddressList()
+		assert.Equal(t, ErrBelowQuorum, vs.CheckQuorum(addrs[:test.qsize-1]), "n=%d", test.n)
+		// the disjoint halves of the set can not both reach quorum
+		assert.False(t, vs.QuorumReached(test.n/2) && vs.QuorumReached(test.n-test.n/2) && test.n > 1, "n=%d", test.n)
+	}
+
+	for n := 1; n <= 20; n++ {
+		vs := newDefaultSet(testAddresses(n), hotstuff.RoundRobin)
+		assert.Equal(t, vs.Q(), vs.QuorumSize(), "n=%d", n)
+		assert.GreaterOrEqual(t, vs.QuorumSize(), 2*vs.F()+1, "n=%d", n)
+		// any two quorums share more than f validators
+		assert.Greater(t, 2*vs.QuorumSize()-n, vs.F(), "n=%d", n)
+
+		addrs := vs.AddressList()
+		assert.NoError(t, vs.CheckQuorum(addrs[:vs.QuorumSize()]), "n=%d", n)
+		assert.Equal(t, ErrBelowQuorum, vs.CheckQuorum(addrs[:vs.QuorumSize()-1]), "n=%d", n)
+	}
+}
+
+func TestAbsentMembers(t *testing.T) {
+	addrs := testAddresses(5)
+	// a custom order, the result is sorted by address nonetheless
+	valSet := NewSetOrdered(addrs, hotstuff.RoundRobin, func(a, b common.Address) bool {
+		return a.Hash().Big().Cmp(b.Hash().Big()) > 0
+	})
+	outsider := common.HexToAddress("0x100")
+
+	committers := []common.Address{addrs[3], outsider, addrs[1], addrs[3], addrs[1]}
+	absent := valSet.AbsentMembers(committers)
+	assert.Equal(t, []common.Address{addrs[0], addrs[2], addrs[4]}, absent)
+
+	assert.Equal(t, addrs, valSet.AbsentMembers(nil))
+	assert.Empty(t, valSet.AbsentMembers(addrs))
+	assert.Empty(t, newDefaultSet(nil, hotstuff.RoundRobin).AbsentMembers(addrs))
+}
+
+func TestQuorumReachedBoundary(t *testing.T) {
+	addrs := testAddresses(4)
+	for _, model := range []hotstuff.FaultModel{hotstuff.BFT, hotstuff.CFT} {
+		vs := NewSetWithFaultModel(addrs, hotstuff.RoundRobin, model)
+		// f=1 and 2f+1=3 under BFT, 4/2+1=3 under CFT: 3 commits pass
+		assert.True(t, vs.QuorumReached(3), model)
+		assert.True(t, vs.QuorumReached(4), model)
+		assert.False(t, vs.QuorumReached(2), model)
+		assert.NoError(t, vs.CheckQuorum(addrs[:3]), model)
+		assert.NoError(t, vs.CheckQuorumStrict(addrs[:3]), model)
+		assert.Equal(t, ErrBelowQuorum, vs.CheckQuorum(addrs[:2]), model)
+
+		bitmap, _ := vs.AddressesToBitmap(addrs[:3])
+		assert.NoError(t, vs.CheckBitmapQuorum(bitmap), model)
+	}
+
+	// the check agrees with QuorumReached for every count
+	for n := 1; n <= 10; n++ {
+		vs := newDefaultSet(testAddresses(n), hotstuff.RoundRobin)
+		list := vs.AddressList()
+		for count := 0; count <= n; count++ {
+			assert.Equal(t, vs.QuorumReached(count), vs.CheckQuorum(list[:count]) == nil, "n=%d count=%d", n, count)
+		}
+	}
+}
+
+func TestNewSetSafe(t *testing.T) {
+	if _, err := NewSetSafe(nil, hotstuff.RoundRobin); !errors.Is(err, ErrInvalidParticipant) || err != ErrEmptySet {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrEmptySet)
+	}
+	addrs := testAddresses(3)
+	if _, err := NewSetSafe(append(addrs, addrs[1]), hotstuff.RoundRobin); err != ErrInvalidParticipant {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidParticipant)
+	}
+	valSet, err := NewSetSafe(addrs, hotstuff.RoundRobin)
+	if err != nil {
+		t.Fatalf("error mismatch: have %v, want nil", err)
+	}
+	assert.Equal(t, 3, valSet.Size())
+}
+
+func TestNewSetDeduplicates(t *testing.T) {
+	addrs := testAddresses(2)
+	valSet := NewSet([]common.Address{addrs[0], addrs[1], addrs[0]}, hotstuff.RoundRobin)
+	assert.Equal(t, 2, valSet.Size())
+	assert.Equal(t, addrs, valSet.AddressList())
+
+	weighted, err := NewWeightedSet([]common.Address{addrs[1], addrs[0], addrs[1]}, []uint64{3, 1, 5}, hotstuff.RoundRobin)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, weighted.Size())
+	_, val := weighted.GetByAddress(addrs[1])
+	assert.Equal(t, uint64(3), val.Weight())
+}
+
+func TestCopyPreservesProposer(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(5), hotstuff.RoundRobin)
+	valSet.CalcProposer(valSet.GetByIndex(1).Address(), 0)
+	proposer := valSet.GetProposer()
+	assert.Equal(t, valSet.GetByIndex(2), proposer)
+
+	cpy := valSet.Copy()
+	assert.Equal(t, proposer, cpy.GetProposer())
+	assert.True(t, cpy.IsProposer(proposer.Address()))
+	assert.False(t, cpy.IsProposer(valSet.GetByIndex(0).Address()))
+}
+
+func TestValidatorSetString(t *testing.T) {
+	addrs := []common.Address{common.HexToAddress("0x2"), common.HexToAddress("0x1")}
+	valSet := newDefaultSet(addrs, hotstuff.Sticky)
+	want := fmt.Sprintf("ValSet{size=2, policy=sticky, proposer=%s, members=[%s, %s]}", addrs[1].String(), addrs[1].String(), addrs[0].String())
+	assert.Equal(t, want, valSet.String())
+	assert.Equal(t, "ValSet{size=0, policy=roundRobin, proposer=nil, members=[]}", newDefaultSet(nil, hotstuff.RoundRobin).String())
+}
+
+func TestCustomSelector(t *testing.T) {
+	// always pick the last validator
+	last := func(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+		return valSet.GetByIndex(uint64(valSet.Size() - 1))
+	}
+	addrs := testAddresses(4)
+	valSet := NewSetWithSelector(addrs, hotstuff.RoundRobin, last)
+	for round := uint64(0); round < 4; round++ {
+		valSet.CalcProposer(addrs[0], round)
+		assert.Equal(t, addrs[3], valSet.GetProposer().Address())
+	}
+	// copies keep the custom selector
+	cpy := valSet.Copy()
+	cpy.CalcProposer(addrs[0], 0)
+	assert.Equal(t, addrs[3], cpy.GetProposer().Address())
+
+	// a nil selector restores the policy default
+	valSet.(*defaultSet).SetSelector(nil)
+	valSet.CalcProposer(addrs[0], 0)
+	assert.Equal(t, addrs[1], valSet.GetProposer().Address())
+}
+
+func TestFAndQCacheInvalidation(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(4), hotstuff.RoundRobin)
+	assert.Equal(t, 1, valSet.F())
+	assert.Equal(t, 3, valSet.Q())
+
+	valSet.AddValidator(common.HexToAddress("0x100"))
+	valSet.AddValidator(common.HexToAddress("0x101"))
+	valSet.AddValidator(common.HexToAddress("0x102"))
+	assert.Equal(t, 2, valSet.F())
+	assert.Equal(t, 5, valSet.Q())
+
+	valSet.RemoveValidator(common.HexToAddress("0x100"))
+	assert.Equal(t, 1, valSet.F())
+	assert.Equal(t, 4, valSet.Q())
+}
+
+// BenchmarkQ compares the cached Q on a set of 100 validators with the former
+// approach, which computed it from the size on every call.
+func BenchmarkQ(b *testing.B) {
+	valSet := newDefaultSet(testAddresses(100), hotstuff.RoundRobin)
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if valSet.Q() == 0 {
+				b.Fatal("no quorum")
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			valSet.validatorMu.RLock()
+			_, q := faultBounds(len(valSet.validators), valSet.faultModel)
+			valSet.validatorMu.RUnlock()
+			if q == 0 {
+				b.Fatal("no quorum")
+			}
+		}
+	})
+}
+
+// BenchmarkCheckQuorum compares CheckQuorum on a set of 200 validators with
+// the former approach, which copied the set and removed every committer.
+func BenchmarkCheckQuorum(b *testing.B) {
+	valSet := newDefaultSet(testAddresses(200), hotstuff.RoundRobin)
+	committers := valSet.AddressList()[:valSet.Q()]
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := valSet.CheckQuorum(committers); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("copy-remove", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cpy := valSet.Copy()
+			validSeal := 0
+			for _, addr := range committers {
+				if cpy.RemoveValidator(addr) {
+					validSeal++
+				}
+			}
+			if validSeal < valSet.Q() {
+				b.Fatal(ErrBelowQuorum)
+			}
+		}
+	})
+}
+
+func TestCheckQuorumDuplicatedCommitter(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(4), hotstuff.RoundRobin)
+	addrs := valSet.AddressList()
+
+	// a duplicated committer counts only once
+	committers := []common.Address{addrs[0], addrs[1], addrs[1]}
+	assert.Equal(t, ErrBelowQuorum, valSet.CheckQuorum(committers))
+	committers = append(committers, addrs[2])
+	assert.NoError(t, valSet.CheckQuorum(committers))
+
+	// non-members are ignored
+	committers = []common.Address{addrs[0], addrs[1], common.HexToAddress("0x100")}
+	assert.Equal(t, ErrBelowQuorum, valSet.CheckQuorum(committers))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		valSet.CheckQuorum(addrs)
+	})
+	assert.Equal(t, float64(0), allocs)
+
+	// sets beyond the stack bitmap still count correctly
+	large := newDefaultSet(testAddresses(300), hotstuff.RoundRobin)
+	all := large.AddressList()
+	assert.NoError(t, large.CheckQuorum(all[100:]))
+	assert.Equal(t, ErrBelowQuorum, large.CheckQuorum(append(all[:large.QuorumSize()-1], all[0])))
+}
+
+func TestCheckQuorumStrict(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(4), hotstuff.RoundRobin)
+	addrs := valSet.AddressList()
+	stranger := common.HexToAddress("0x100")
+
+	committers := []common.Address{addrs[0], addrs[1], addrs[2], stranger}
+	assert.NoError(t, valSet.CheckQuorum(committers))
+
+	err := valSet.CheckQuorumStrict(committers)
+	assert.True(t, errors.Is(err, ErrNonMember))
+	assert.Contains(t, err.Error(), stranger.Hex())
+
+	assert.NoError(t, valSet.CheckQuorumStrict(committers[:3]))
+	assert.Equal(t, ErrBelowQuorum, valSet.CheckQuorumStrict(committers[:2]))
+
+	// oversized committers are rejected early, while the lenient check
+	// still counts the distinct members
+	oversized := []common.Address{addrs[0], addrs[1], addrs[2], addrs[3], addrs[0]}
+	err = valSet.CheckQuorumStrict(oversized)
+	assert.True(t, errors.Is(err, ErrTooManyCommitters))
+	assert.Contains(t, err.Error(), "have 5, want at most 4")
+	assert.NoError(t, valSet.CheckQuorum(oversized))
+}
+
+func TestProposerForRound(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(5), hotstuff.RoundRobin)
+	last := valSet.GetByIndex(1).Address()
+	current := valSet.GetProposer()
+
+	for round := uint64(0); round < 10; round++ {
+		predicted := valSet.ProposerForRound(last, round)
+		assert.Equal(t, current, valSet.GetProposer())
+
+		cpy := valSet.Copy()
+		cpy.CalcProposer(last, round)
+		assert.Equal(t, cpy.GetProposer(), predicted)
+	}
+}
+
+func TestNextProposer(t *testing.T) {
+	addrs := testAddresses(5)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	last := addrs[1]
+	for round := uint64(0); round < 10; round++ {
+		valSet.CalcProposer(last, round)
+		current := valSet.GetProposer()
+
+		next := valSet.NextProposer()
+		assert.Equal(t, current, valSet.GetProposer())
+		assert.Equal(t, valSet.ProposerForRound(last, round+1), next)
+	}
+
+	// the sticky leader stays in charge
+	sticky := newDefaultSet(addrs, hotstuff.Sticky)
+	sticky.CalcProposer(addrs[3], 2)
+	assert.Equal(t, addrs[3], sticky.NextProposer().Address())
+
+	assert.Nil(t, newDefaultSet(nil, hotstuff.RoundRobin).NextProposer())
+}
+
+func TestNewSetOrdered(t *testing.T) {
+	addrs := testAddresses(5)
+	descending := func(a, b common.Address) bool {
+		return bytes.Compare(a.Bytes(), b.Bytes()) > 0
+	}
+	valSet := NewSetOrdered([]common.Address{addrs[2], addrs[4], addrs[0], addrs[3], addrs[1]}, hotstuff.RoundRobin, descending)
+	want := []common.Address{addrs[4], addrs[3], addrs[2], addrs[1], addrs[0]}
+	assert.Equal(t, want, valSet.AddressList())
+	assert.Equal(t, addrs[4], valSet.GetProposer().Address())
+
+	// round robin follows the custom order
+	valSet.CalcProposer(addrs[4], 0)
+	assert.Equal(t, addrs[3], valSet.GetProposer().Address())
+
+	// the order is kept by additions and copies
+	valSet.AddValidator(common.HexToAddress("0x100"))
+	assert.Equal(t, common.HexToAddress("0x100"), valSet.GetByIndex(0).Address())
+	assert.Equal(t, valSet.AddressList(), valSet.Copy().AddressList())
+
+	idx, _ := valSet.GetByAddress(addrs[0])
+	assert.Equal(t, 5, idx)
+
+	// nil falls back to the ascending order
+	assert.Equal(t, addrs, NewSetOrdered(want, hotstuff.RoundRobin, nil).AddressList())
+}
+
+func TestIsProposer(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(4), hotstuff.RoundRobin)
+	valSet.CalcProposer(valSet.GetByIndex(1).Address(), 0)
+
+	for i, v := range valSet.List() {
+		assert.Equal(t, i == 2, valSet.IsProposer(v.Address()))
+		assert.Equal(t, i == 2, valSet.IsProposerIndex(uint64(i)))
+	}
+	assert.False(t, valSet.IsProposerIndex(4))
+	assert.False(t, valSet.IsProposer(common.HexToAddress("0x100")))
+
+	empty := newDefaultSet(nil, hotstuff.RoundRobin)
+	assert.False(t, empty.IsProposer(common.Address{}))
+	assert.False(t, empty.IsProposerIndex(0))
+}
+
+func TestContains(t *testing.T) {
+	addrs := testAddresses(3)
+	valSet := NewSet(addrs, hotstuff.RoundRobin)
+	for _, addr := range addrs {
+		assert.True(t, valSet.Contains(addr))
+	}
+	assert.False(t, valSet.Contains(common.HexToAddress("0x100")))
+
+	valSet.RemoveValidator(addrs[1])
+	assert.False(t, valSet.Contains(addrs[1]))
+	valSet.AddValidator(common.HexToAddress("0x100"))
+	assert.True(t, valSet.Contains(common.HexToAddress("0x100")))
+}
+
+func TestBrokenSelectorFallback(t *testing.T) {
+	stranger := func(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+		return New(common.HexToAddress("0x100"))
+	}
+	none := func(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+		return nil
+	}
+	for _, selector := range []hotstuff.ProposalSelector{stranger, none} {
+		valSet := NewSetWithSelector(testAddresses(4), hotstuff.RoundRobin, selector)
+		for round := uint64(0); round < 8; round++ {
+			valSet.CalcProposer(common.Address{}, round)
+			assert.Equal(t, valSet.GetByIndex(round%4), valSet.GetProposer())
+		}
+	}
+}
+
+func TestCheckWeightedQuorum(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet, _ := NewWeightedSet(addrs, []uint64{70, 10, 10, 10}, hotstuff.RoundRobin)
+
+	// 70 out of 100 is more than 2/3
+	assert.NoError(t, valSet.CheckWeightedQuorum([]common.Address{addrs[0]}))
+	// duplicates count once and 30 is far from enough
+	assert.Equal(t, ErrBelowQuorum, valSet.CheckWeightedQuorum([]common.Address{addrs[1], addrs[2], addrs[3], addrs[3]}))
+
+	valSet, _ = NewWeightedSet(addrs, []uint64{60, 20, 10, 10}, hotstuff.RoundRobin)
+	assert.Equal(t, ErrBelowQuorum, valSet.CheckWeightedQuorum([]common.Address{addrs[0]}))
+	assert.NoError(t, valSet.CheckWeightedQuorum([]common.Address{addrs[0], addrs[2]}))
+
+	// exactly 2/3 is not enough
+	valSet, _ = NewWeightedSet(addrs[:3], []uint64{2, 2, 2}, hotstuff.RoundRobin)
+	assert.Equal(t, ErrBelowQuorum, valSet.CheckWeightedQuorum(addrs[:2]))
+	assert.NoError(t, valSet.CheckWeightedQuorum(addrs[:3]))
+
+	// huge stakes must not overflow
+	max := uint64(math.MaxUint64 / 4)
+	valSet, _ = NewWeightedSet(addrs[:3], []uint64{max, max, max}, hotstuff.RoundRobin)
+	assert.Equal(t, ErrBelowQuorum, valSet.CheckWeightedQuorum(addrs[:2]))
+	assert.NoError(t, valSet.CheckWeightedQuorum(addrs[:3]))
+}
+
+func TestOrderTiebreak(t *testing.T) {
+	addrs := testAddresses(5)
+	weights := []uint64{5, 1, 5, 1, 5}
+	valSet, err := NewWeightedSetOrdered([]common.Address{addrs[4], addrs[3], addrs[2], addrs[1], addrs[0]}, []uint64{5, 1, 5, 1, 5}, hotstuff.RoundRobin, ByWeight)
+	if err != nil {
+		t.Fatalf("failed to create weighted set: %v", err)
+	}
+	// heavier first, equal weights ordered by address whatever the input order
+	want := []common.Address{addrs[0], addrs[2], addrs[4], addrs[1], addrs[3]}
+	assert.Equal(t, want, valSet.AddressList())
+
+	other, _ := NewWeightedSetOrdered(addrs, weights, hotstuff.RoundRobin, ByWeight)
+	assert.Equal(t, want, other.AddressList())
+
+	// a custom address order considering everything equal falls back to the address
+	same := func(a, b common.Address) bool { return false }
+	assert.Equal(t, addrs, NewSetOrdered([]common.Address{addrs[3], addrs[1], addrs[4], addrs[0], addrs[2]}, hotstuff.RoundRobin, same).AddressList())
+}
+
+func TestBatchAddAndRemove(t *testing.T) {
+	addrs := testAddresses(6)
+	valSet := newDefaultSet(addrs[:2], hotstuff.RoundRobin)
+
+	assert.Equal(t, 3, valSet.AddValidators([]common.Address{addrs[4], addrs[1], addrs[2], addrs[4], addrs[3]}))
+	assert.Equal(t, addrs[:5], valSet.AddressList())
+	assert.Equal(t, 0, valSet.AddValidators(addrs[:2]))
+	for i, addr := range addrs[:5] {
+		idx, _ := valSet.GetByAddress(addr)
+		assert.Equal(t, i, idx)
+	}
+
+	assert.Equal(t, 2, valSet.RemoveValidators([]common.Address{addrs[1], addrs[5], addrs[3], addrs[1]}))
+	assert.Equal(t, []common.Address{addrs[0], addrs[2], addrs[4]}, valSet.AddressList())
+	assert.Equal(t, 0, valSet.RemoveValidators([]common.Address{addrs[5]}))
+	idx, _ := valSet.GetByAddress(addrs[4])
+	assert.Equal(t, 2, idx)
+	assert.Equal(t, 0, valSet.F())
+}
+
+func benchmarkAdd(b *testing.B, batch bool) {
+	addrs := testAddresses(100)
+	for i := 0; i < b.N; i++ {
+		valSet := newDefaultSet(addrs[:50], hotstuff.RoundRobin)
+		if batch {
+			valSet.AddValidators(addrs[50:])
+		} else {
+			for _, addr := range addrs[50:] {
+				valSet.AddValidator(addr)
+			}
+		}
+	}
+}
+
+func BenchmarkAddValidatorRepeated(b *testing.B) { benchmarkAdd(b, false) }
+func BenchmarkAddValidatorsBatch(b *testing.B)   { benchmarkAdd(b, true) }
+
+func BenchmarkAddValidatorSorted(b *testing.B) {
+	addrs := testAddresses(1000)
+	valSet := newDefaultSet(addrs[:500], hotstuff.RoundRobin)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		addr := addrs[500+i%500]
+		valSet.AddValidator(addr)
+		b.StopTimer()
+		valSet.RemoveValidator(addr)
+		b.StartTimer()
+	}
+}
+
+func TestAddValidatorSorted(t *testing.T) {
+	addrs := testAddresses(20)
+	valSet := newDefaultSet(nil, hotstuff.RoundRobin)
+	for _, i := range rand.Perm(len(addrs)) {
+		assert.True(t, valSet.AddValidator(addrs[i]))
+		assert.False(t, valSet.AddValidator(addrs[i]))
+	}
+	assert.Equal(t, newDefaultSet(addrs, hotstuff.RoundRobin).AddressList(), valSet.AddressList())
+	for i, addr := range valSet.AddressList() {
+		idx, _ := valSet.GetByAddress(addr)
+		assert.Equal(t, i, idx)
+	}
+
+	// custom orders are respected as well
+	weighted, _ := NewWeightedSetOrdered(addrs[:3], []uint64{3, 1, 2}, hotstuff.RoundRobin, ByWeight)
+	weighted.AddValidator(addrs[3])
+	assert.Equal(t, []common.Address{addrs[0], addrs[2], addrs[1], addrs[3]}, weighted.AddressList())
+}
+
+func TestMustGetByIndex(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(3), hotstuff.RoundRobin)
+	val, err := valSet.MustGetByIndex(2)
+	assert.NoError(t, err)
+	assert.Equal(t, valSet.GetByIndex(2), val)
+
+	for _, i := range []uint64{3, math.MaxUint64} {
+		val, err = valSet.MustGetByIndex(i)
+		assert.Nil(t, val)
+		assert.True(t, errors.Is(err, ErrInvalidParticipant))
+	}
+	_, err = newDefaultSet(nil, hotstuff.RoundRobin).MustGetByIndex(0)
+	assert.True(t, errors.Is(err, ErrInvalidParticipant))
+}
+
+func TestProposerSchedule(t *testing.T) {
+	for _, policy := range []hotstuff.SelectProposerPolicy{hotstuff.RoundRobin, hotstuff.Sticky, hotstuff.VRF} {
+		valSet := newDefaultSet(testAddresses(5), policy)
+		last := valSet.GetByIndex(2).Address()
+		current := valSet.GetProposer()
+
+		schedule := valSet.ProposerSchedule(last, 12)
+		assert.Equal(t, 12, len(schedule))
+		assert.Equal(t, current, valSet.GetProposer())
+
+		cpy := valSet.Copy()
+		for round, addr := range schedule {
+			cpy.CalcProposer(last, uint64(round))
+			assert.Equal(t, cpy.GetProposer().Address(), addr)
+		}
+	}
+	assert.Nil(t, newDefaultSet(nil, hotstuff.RoundRobin).ProposerSchedule(common.Address{}, 3))
+}
+
+func TestRemovedLastProposer(t *testing.T) {
+	addrs := testAddresses(5)
+	for _, removed := range []int{0, 2, 4} {
+		rr := newDefaultSet(addrs, hotstuff.RoundRobin)
+		sticky := newDefaultSet(addrs, hotstuff.Sticky)
+		rr.RemoveValidator(addrs[removed])
+		sticky.RemoveValidator(addrs[removed])
+
+		// the surviving successor of the removed proposer takes over
+		successor := addrs[(removed+1)%len(addrs)]
+		rr.CalcProposer(addrs[removed], 0)
+		sticky.CalcProposer(addrs[removed], 0)
+		assert.Equal(t, successor, rr.GetProposer().Address())
+		assert.Equal(t, successor, sticky.GetProposer().Address())
+
+		// and round robin rotates from there while sticky sticks to it for
+		// stickyRounds rounds at a time
+		for round := uint64(1); round < 8; round++ {
+			rr.CalcProposer(addrs[removed], round)
+			sticky.CalcProposer(addrs[removed], round)
+			idx, _ := rr.GetByAddress(successor)
+			assert.Equal(t, rr.GetByIndex((uint64(idx)+round)%uint64(rr.Size())), rr.GetProposer())
+			assert.Equal(t, sticky.GetByIndex((uint64(idx)+round/stickyRounds)%uint64(sticky.Size())), sticky.GetProposer())
+			cpy := rr.Copy()
+			cpy.CalcProposer(addrs[removed], round)
+			assert.Equal(t, rr.GetProposer(), cpy.GetProposer())
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+
+	assert.True(t, valSet.Equal(valSet.Copy()))
+	assert.True(t, valSet.Cmp(valSet.Copy()))
+
+	// same members, different policy
+	sticky := newDefaultSet(addrs, hotstuff.Sticky)
+	assert.True(t, valSet.Cmp(sticky))
+	assert.False(t, valSet.Equal(sticky))
+
+	// same members, different order
+	reversed := NewSetOrdered(addrs, hotstuff.RoundRobin, func(a, b common.Address) bool {
+		return bytes.Compare(a.Bytes(), b.Bytes()) > 0
+	})
+	assert.True(t, valSet.Cmp(reversed))
+	assert.False(t, valSet.Equal(reversed))
+
+	// different members
+	fewer := newDefaultSet(addrs[:3], hotstuff.RoundRobin)
+	assert.False(t, valSet.Cmp(fewer))
+	assert.False(t, valSet.Equal(fewer))
+}
+
+func TestCompare(t *testing.T) {
+	addrs := testAddresses(5)
+	valSet := newDefaultSet(addrs[:4], hotstuff.RoundRobin)
+
+	testCases := []struct {
+		src    hotstuff.ValidatorSet
+		equal  bool
+		index  int
+		reason string
+	}{
+		{valSet.Copy(), true, -1, ""},
+		{newDefaultSet(addrs[:4], hotstuff.Sticky), false, -1, "policy mismatch: have roundRobin, want sticky"},
+		{newDefaultSet([]common.Address{addrs[0], addrs[1], addrs[4], addrs[3]}, hotstuff.RoundRobin), false, 2,
+			fmt.Sprintf("address mismatch at index 2: have %s, want %s", addrs[2].Hex(), addrs[3].Hex())},
+		{newDefaultSet(addrs[:3], hotstuff.RoundRobin), false, 3, "size mismatch: have 4, want 3"},
+		{newDefaultSet(addrs, hotstuff.RoundRobin), false, 4, "size mismatch: have 4, want 5"},
+	}
+	for i, test := range testCases {
+		equal, index, reason := valSet.Compare(test.src)
+		if equal != test.equal || index != test.index || reason != test.reason {
+			t.Errorf("test %d: result mismatch: have %v, %d, %q, want %v, %d, %q", i, equal, index, reason, test.equal, test.index, test.reason)
+		}
+		assert.Equal(t, test.equal, valSet.Equal(test.src), "test %d", i)
+	}
+}
+
+func TestNewSetFrom(t *testing.T) {
+	addrs := testAddresses(4)
+	src, err := NewWeightedSet(addrs, []uint64{1, 2, 3, 4}, hotstuff.Sticky)
+	if err != nil {
+		t.Fatalf("failed to create weighted set: %v", err)
+	}
+	valSet := NewSetFrom(src)
+	assert.True(t, valSet.Equal(src))
+	assert.Equal(t, src.TotalWeight(), valSet.TotalWeight())
+
+	// the new set is independent of its source
+	valSet.RemoveValidator(addrs[0])
+	assert.Equal(t, 4, src.Size())
+	assert.Equal(t, 3, valSet.Size())
+}
+
+func TestBLSPublicKeys(t *testing.T) {
+	addrs := testAddresses(3)
+	keys := [][]byte{{0x03}, nil, {0x01, 0x02}}
+	valSet, err := NewBLSSet([]common.Address{addrs[2], addrs[1], addrs[0]}, keys, hotstuff.RoundRobin)
+	if err != nil {
+		t.Fatalf("failed to create bls set: %v", err)
+	}
+	if _, err := NewBLSSet(addrs, keys[:2], hotstuff.RoundRobin); err != ErrInvalidParticipant {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidParticipant)
+	}
+
+	// the keys follow the validator order, the address list stays as is
+	assert.Equal(t, [][]byte{{0x01, 0x02}, nil, {0x03}}, valSet.BLSPublicKeys())
+	assert.Equal(t, addrs, valSet.AddressList())
+	_, val := valSet.GetByAddress(addrs[2])
+	assert.Equal(t, []byte{0x03}, val.BLSPublicKey())
+	assert.Nil(t, New(addrs[0]).BLSPublicKey())
+
+	// the keys are copied in and out
+	key := []byte{0x04}
+	val = NewWithBLS(addrs[0], key)
+	key[0] = 0
+	val.BLSPublicKey()[0] = 0
+	assert.Equal(t, []byte{0x04}, val.BLSPublicKey())
+
+	// legacy members join without a key, copies and vote-only members keep theirs
+	valSet.AddValidator(common.HexToAddress("0x100"))
+	valSet.SetProposerEligible(addrs[2], false)
+	assert.Equal(t, valSet.BLSPublicKeys(), NewSetFrom(valSet).BLSPublicKeys())
+	assert.Equal(t, [][]byte{{0x01, 0x02}, nil, {0x03}, nil}, valSet.Copy().BLSPublicKeys())
+}
+
+func TestDeepCopy(t *testing.T) {
+	addrs := testAddresses(5)
+	valSet := NewSetWithFaultModel(addrs, hotstuff.RoundRobin, hotstuff.CFT)
+	valSet.CalcProposer(addrs[0], 0)
+	valSet.CalcProposer(addrs[1], 0)
+	valSet.SetLastProposer(addrs[1])
+	valSet.Jail(addrs[3])
+	valSet.SetProposerEligible(addrs[4], false)
+
+	cpy := valSet.DeepCopy()
+	assert.Equal(t, valSet.GetProposer(), cpy.GetProposer())
+	assert.Equal(t, valSet.LastProposer(), cpy.LastProposer())
+	assert.Equal(t, valSet.Params(), cpy.Params())
+	assert.Equal(t, valSet.(RecentProposersReader).RecentProposers(10), cpy.(RecentProposersReader).RecentProposers(10))
+	assert.True(t, cpy.IsJailed(addrs[3]))
+	_, val := cpy.GetByAddress(addrs[4])
+	assert.False(t, val.ProposerEligible())
+	assert.Equal(t, valSet.NextProposer(), cpy.NextProposer())
+	assertSelectorConsistent(t, valSet, cpy, 20)
+
+	// advancing the clone leaves the original unchanged
+	proposer, recent := valSet.GetProposer(), valSet.(RecentProposersReader).RecentProposers(10)
+	cpy.AdvanceProposer()
+	cpy.CalcProposer(addrs[2], 3)
+	cpy.Jail(addrs[0])
+	cpy.Unjail(addrs[3])
+	cpy.SetProposerEligible(addrs[4], true)
+	cpy.RemoveValidator(addrs[1])
+	assert.Equal(t, proposer, valSet.GetProposer())
+	assert.Equal(t, recent, valSet.(RecentProposersReader).RecentProposers(10))
+	assert.False(t, valSet.IsJailed(addrs[0]))
+	assert.True(t, valSet.IsJailed(addrs[3]))
+	_, val = valSet.GetByAddress(addrs[4])
+	assert.False(t, val.ProposerEligible())
+	assert.Equal(t, 5, valSet.Size())
+	assert.Equal(t, 2, valSet.F())
+
+	// and the other way round
+	cpy = valSet.DeepCopy()
+	valSet.CalcProposer(addrs[2], 1)
+	valSet.Jail(addrs[1])
+	assert.Equal(t, proposer, cpy.GetProposer())
+	assert.False(t, cpy.IsJailed(addrs[1]))
+
+	// Copy carries the jailed validators, which selection depends on, but
+	// not the history
+	assert.True(t, valSet.Copy().IsJailed(addrs[3]))
+	assert.Empty(t, valSet.Copy().(RecentProposersReader).RecentProposers(10))
+}
+
+func TestProposerIndex(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(5), hotstuff.RoundRobin)
+	for round := uint64(0); round < 10; round++ {
+		valSet.CalcProposer(valSet.GetByIndex(2).Address(), round)
+		idx := valSet.ProposerIndex()
+		assert.Equal(t, valSet.GetProposer(), valSet.GetByIndex(uint64(idx)))
+		assert.True(t, valSet.IsProposerIndex(uint64(idx)))
+	}
+
+	empty := newDefaultSet(nil, hotstuff.RoundRobin)
+	assert.Equal(t, -1, empty.ProposerIndex())
+}
+
+func TestCalcSeedOverflow(t *testing.T) {
+	for _, n := range []int{1, 3, 5, 7} {
+		addrs := testAddresses(n)
+		valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+		sticky := newDefaultSet(addrs, hotstuff.Sticky)
+		for _, last := range []common.Address{{}, addrs[n-1], common.HexToAddress("0xff")} {
+			// the rotation carries on across the uint64 boundary
+			prev := valSet.ProposerForRound(last, math.MaxUint64-3)
+			for _, round := range []uint64{math.MaxUint64 - 2, math.MaxUint64 - 1, math.MaxUint64} {
+				proposer := valSet.ProposerForRound(last, round)
+				idx, _ := valSet.GetByAddress(prev.Address())
+				want := valSet.GetByIndex(uint64(idx+1) % uint64(n))
+				if proposer.Address() != want.Address() {
+					t.Errorf("n %d, round %d: proposer mismatch: have %v, want %v", n, round, proposer, want)
+				}
+				prev = proposer
+			}
+		}
+		want := addrs[(uint64(n-1)+(math.MaxUint64/stickyRounds)%uint64(n))%uint64(n)]
+		assert.Equal(t, want, sticky.ProposerForRound(addrs[n-1], math.MaxUint64).Address())
+	}
+}
+
+func TestGenesisProposer(t *testing.T) {
+	addrs := testAddresses(4)
+	genesis := common.Address{}
+
+	// by default the round alone picks the proposer
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	for round := uint64(0); round < 8; round++ {
+		assert.Equal(t, addrs[round%4], valSet.ProposerForRound(genesis, round).Address())
+	}
+
+	valSet.SetGenesisProposer(addrs[2])
+	assert.Equal(t, addrs[2], valSet.GenesisProposer())
+	for round := uint64(0); round < 8; round++ {
+		assert.Equal(t, addrs[(2+round)%4], valSet.ProposerForRound(genesis, round).Address())
+	}
+	// a known last proposer takes precedence
+	assert.Equal(t, addrs[1], valSet.ProposerForRound(addrs[0], 0).Address())
+	// and the setting is carried over by copies
+	assert.Equal(t, addrs[2], valSet.Copy().ProposerForRound(genesis, 0).Address())
+
+	// sticky keeps the genesis proposer for a few rounds, a jailed one is
+	// skipped
+	sticky := newDefaultSet(addrs, hotstuff.Sticky)
+	sticky.SetGenesisProposer(addrs[3])
+	assert.Equal(t, addrs[3], sticky.ProposerForRound(genesis, 0).Address())
+	assert.Equal(t, addrs[3], sticky.ProposerForRound(genesis, stickyRounds).Address())
+	assert.Equal(t, addrs[0], sticky.ProposerForRound(genesis, stickyRounds+1).Address())
+	sticky.Jail(addrs[3])
+	assert.Equal(t, addrs[0], sticky.ProposerForRound(genesis, 0).Address())
+
+	// a genesis proposer which is not a validator is ignored
+	valSet.SetGenesisProposer(common.HexToAddress("0x100"))
+	assert.Equal(t, addrs[1], valSet.ProposerForRound(genesis, 1).Address())
+}
+
+func TestCalcProposerGlobal(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	const roundsPerEpoch = 3
+
+	// the schedule goes on across the epoch boundary instead of starting over
+	var schedule []common.Address
+	for epoch := uint64(0); epoch < 3; epoch++ {
+		for round := uint64(0); round < roundsPerEpoch; round++ {
+			valSet.CalcProposerGlobal(common.Address{}, epoch, round, roundsPerEpoch)
+			schedule = append(schedule, valSet.GetProposer().Address())
+		}
+	}
+	for i, addr := range schedule {
+		if want := addrs[i%4]; addr != want {
+			t.Errorf("global round %d: proposer mismatch: have %v, want %v", i, addr, want)
+		}
+	}
+
+	valSet.CalcProposerGlobal(addrs[1], 1, 0, roundsPerEpoch)
+	assert.Equal(t, valSet.ProposerForRound(addrs[1], 3), valSet.GetProposer())
+	valSet.CalcProposerGlobal(addrs[1], 0, 2, roundsPerEpoch)
+	assert.Equal(t, valSet.ProposerForRound(addrs[1], 2), valSet.GetProposer())
+
+	assert.Equal(t, uint64(3*7+2), GlobalRound(3, 2, 7))
+	assert.Equal(t, uint64(5), GlobalRound(0, 5, 0))
+}
+
+func TestSeed(t *testing.T) {
+	addrs := testAddresses(5)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	for round := uint64(0); round < 12; round++ {
+		for i, last := range addrs {
+			seed := valSet.Seed(last, round)
+			if want := (uint64(i) + round) % 5; seed != want {
+				t.Errorf("last %d, round %d: seed mismatch: have %d, want %d", i, round, seed, want)
+			}
+			// round robin picks the validator after the seed
+			assert.Equal(t, valSet.GetByIndex((seed+1)%5), valSet.ProposerForRound(last, round))
+		}
+	}
+	// a removed proposer is placed at its successor
+	valSet.RemoveValidator(addrs[2])
+	assert.Equal(t, uint64(3), valSet.Seed(addrs[2], 1))
+	assert.Equal(t, uint64(0), newDefaultSet(nil, hotstuff.RoundRobin).Seed(addrs[0], 7))
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	addrs := testAddresses(4)
+	weights := []uint64{1, 2, 3, 4}
+	valSet, err := NewWeightedSet(addrs, weights, hotstuff.WeightedRoundRobin)
+	if err != nil {
+		t.Fatalf("failed to create weighted set: %v", err)
+	}
+
+	const rounds = 10000
+	picked := make(map[common.Address]int)
+	for round := uint64(0); round < rounds; round++ {
+		valSet.CalcProposer(addrs[1], round)
+		picked[valSet.GetProposer().Address()]++
+	}
+	total := valSet.TotalWeight()
+	for i, addr := range addrs {
+		want := float64(rounds) * float64(weights[i]) / float64(total)
+		if have := float64(picked[addr]); math.Abs(have-want) > 0.05*want {
+			t.Errorf("validator %d picked %v times, want about %v", i, have, want)
+		}
+	}
+
+	// the rotation starts right after the slots of the last proposer
+	assert.Equal(t, addrs[2], valSet.ProposerForRound(addrs[1], 0).Address())
+	assert.Equal(t, addrs[3], valSet.ProposerForRound(addrs[1], 3).Address())
+	assert.Equal(t, addrs[0], valSet.ProposerForRound(addrs[1], 7).Address())
+
+	// unweighted sets rotate like round robin
+	rr := newDefaultSet(addrs, hotstuff.RoundRobin)
+	wrr := newDefaultSet(addrs, hotstuff.WeightedRoundRobin)
+	for round := uint64(0); round < 10; round++ {
+		assert.Equal(t, rr.ProposerForRound(addrs[2], round), wrr.ProposerForRound(addrs[2], round))
+	}
+}
+
+func TestRemoveValidatorByIndex(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	list := valSet.List()
+
+	assert.False(t, valSet.RemoveValidatorByIndex(4))
+	assert.True(t, valSet.RemoveValidatorByIndex(1))
+	assert.Equal(t, []common.Address{addrs[0], addrs[2], addrs[3]}, valSet.AddressList())
+	idx, _ := valSet.GetByAddress(addrs[3])
+	assert.Equal(t, 2, idx)
+	// previously returned lists are left untouched
+	assert.Equal(t, addrs[1], list[1].Address())
+
+	// removing the proposer hands over to its successor
+	valSet.CalcProposerByIndex(2)
+	assert.True(t, valSet.IsProposer(addrs[2]))
+	assert.True(t, valSet.RemoveValidatorByIndex(1))
+	assert.True(t, valSet.IsProposer(addrs[3]))
+
+	valSet.CalcProposerByIndex(2)
+	assert.True(t, valSet.IsProposer(addrs[3]))
+	assert.True(t, valSet.RemoveValidatorByIndex(1))
+	assert.True(t, valSet.IsProposer(addrs[0]))
+
+	assert.True(t, valSet.RemoveValidatorByIndex(0))
+	assert.Nil(t, valSet.GetProposer())
+	assert.Equal(t, 0, valSet.Size())
+}
+
+func TestRemoveValidatorGet(t *testing.T) {
+	addrs := testAddresses(3)
+	valSet, err := NewWeightedSet(addrs, []uint64{1, 5, 2}, hotstuff.RoundRobin)
+	if err != nil {
+		t.Fatalf("failed to create weighted set: %v", err)
+	}
+
+	removed, ok := valSet.RemoveValidatorGet(addrs[1])
+	if !ok {
+		t.Fatalf("failed to remove validator %x", addrs[1])
+	}
+	if removed.Address() != addrs[1] {
+		t.Errorf("removed validator mismatch: have %x, want %x", removed.Address(), addrs[1])
+	}
+	assert.Equal(t, uint64(5), removed.Weight())
+	assert.False(t, valSet.Contains(addrs[1]))
+
+	removed, ok = valSet.RemoveValidatorGet(addrs[1])
+	assert.False(t, ok)
+	assert.Nil(t, removed)
+	assert.Equal(t, 2, valSet.Size())
+}
+
+func TestRemoveProposer(t *testing.T) {
+	addrs := testAddresses(6)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+
+	valSet.CalcProposer(addrs[1], 0)
+	assert.True(t, valSet.IsProposer(addrs[2]))
+	assert.True(t, valSet.RemoveValidator(addrs[2]))
+	assert.True(t, valSet.Contains(valSet.GetProposer().Address()))
+	assert.True(t, valSet.IsProposer(addrs[3]))
+
+	// batch removal skips successors removed alongside the proposer
+	assert.Equal(t, 2, valSet.RemoveValidators([]common.Address{addrs[4], addrs[3]}))
+	assert.True(t, valSet.IsProposer(addrs[5]))
+	assert.Equal(t, 1, valSet.RemoveValidators([]common.Address{addrs[5]}))
+	assert.True(t, valSet.IsProposer(addrs[0]))
+
+	// removing anyone else keeps the proposer
+	assert.True(t, valSet.RemoveValidator(addrs[1]))
+	assert.True(t, valSet.IsProposer(addrs[0]))
+
+	assert.True(t, valSet.RemoveValidator(addrs[0]))
+	assert.Nil(t, valSet.GetProposer())
+}
+
+func TestHealthCheck(t *testing.T) {
+	for n := 0; n <= 7; n++ {
+		valSet := newDefaultSet(testAddresses(n), hotstuff.RoundRobin)
+		assert.Equal(t, n > 0, valSet.CanReachQuorum(), "size %d", n)
+		err := valSet.HealthCheck()
+		if n < 4 {
+			assert.True(t, errors.Is(err, ErrTooFewValidators), "size %d", n)
+		} else {
+			assert.NoError(t, err, "size %d", n)
+		}
+	}
+
+	// a collapsing set is detected
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	assert.NoError(t, valSet.HealthCheck())
+	valSet.RemoveValidator(addrs[0])
+	assert.Error(t, valSet.HealthCheck())
+	valSet.RemoveValidators(addrs)
+	assert.False(t, valSet.CanReachQuorum())
+}
+
+func TestMinQuorumCommitters(t *testing.T) {
+	for n := 1; n <= 10; n++ {
+		for _, model := range []hotstuff.FaultModel{hotstuff.BFT, hotstuff.CFT} {
+			valSet := NewSetWithFaultModel(testAddresses(n), hotstuff.RoundRobin, model)
+			committers := valSet.MinQuorumCommitters()
+			assert.Equal(t, valSet.QuorumSize(), len(committers), "%v n=%d", model, n)
+			assert.NoError(t, valSet.CheckQuorumStrict(committers), "%v n=%d", model, n)
+			assert.Equal(t, ErrBelowQuorum, valSet.CheckQuorum(committers[1:]), "%v n=%d", model, n)
+		}
+	}
+	assert.Empty(t, newDefaultSet(nil, hotstuff.RoundRobin).MinQuorumCommitters())
+
+	// the heaviest validators make a weighted quorum
+	addrs := testAddresses(5)
+	weighted, _ := NewWeightedSet(addrs, []uint64{1, 4, 2, 4, 1}, hotstuff.RoundRobin)
+	committers := weighted.MinQuorumCommitters()
+	assert.Equal(t, []common.Address{addrs[1], addrs[2], addrs[3]}, committers)
+	assert.NoError(t, weighted.CheckWeightedQuorum(committers))
+	for i := range committers {
+		fewer := append(copyAddresses(committers[:i]), committers[i+1:]...)
+		assert.Equal(t, ErrBelowQuorum, weighted.CheckWeightedQuorum(fewer))
+	}
+}
+
+func TestFaultModel(t *testing.T) {
+	testCases := []struct {
+		n     int
+		model hotstuff.FaultModel
+		f, q  int
+		qsize int
+	}{
+		{4, hotstuff.BFT, 1, 3, 3},
+		{4, hotstuff.CFT, 1, 3, 3},
+		{5, hotstuff.BFT, 1, 4, 4},
+		{5, hotstuff.CFT, 2, 3, 3},
+		{7, hotstuff.BFT, 2, 5, 5},
+		{7, hotstuff.CFT, 3, 4, 4},
+		{1, hotstuff.CFT, 0, 1, 1},
+	}
+	for _, test := range testCases {
+		addrs := testAddresses(test.n)
+		valSet := NewSetWithFaultModel(addrs, hotstuff.RoundRobin, test.model)
+		assert.Equal(t, test.model, valSet.FaultModel())
+		assert.Equal(t, test.f, valSet.F(), "%v n=%d", test.model, test.n)
+		assert.Equal(t, test.q, valSet.Q(), "%v n=%d", test.model, test.n)
+		assert.Equal(t, test.qsize, valSet.QuorumSize(), "%v n=%d", test.model, test.n)
+
+		// the model survives copies and membership changes
+		cpy := valSet.Copy()
+		assert.Equal(t, test.model, cpy.FaultModel())
+		cpy.AddValidator(common.HexToAddress("0x100"))
+		assert.Equal(t, NewSetWithFaultModel(cpy.AddressList(), hotstuff.RoundRobin, test.model).Params(), cpy.Params())
+	}
+	assert.Equal(t, hotstuff.BFT, newDefaultSet(testAddresses(4), hotstuff.RoundRobin).FaultModel())
+
+	// 3 of 5 commit only under CFT, BFT needs 4, as do 4 of 7
+	addrs := testAddresses(7)
+	bft := NewSetWithFaultModel(addrs[:5], hotstuff.RoundRobin, hotstuff.BFT)
+	cft := NewSetWithFaultModel(addrs[:5], hotstuff.RoundRobin, hotstuff.CFT)
+	assert.NoError(t, bft.CheckQuorum(addrs[:4]))
+	assert.NoError(t, cft.CheckQuorum(addrs[:3]))
+	assert.Equal(t, ErrBelowQuorum, bft.CheckQuorum(addrs[:3]))
+	assert.Equal(t, ErrBelowQuorum, cft.CheckQuorum(addrs[:2]))
+	bft = NewSetWithFaultModel(addrs, hotstuff.RoundRobin, hotstuff.BFT)
+	cft = NewSetWithFaultModel(addrs, hotstuff.RoundRobin, hotstuff.CFT)
+	assert.Equal(t, ErrBelowQuorum, bft.CheckQuorum(addrs[:4]))
+	assert.NoError(t, cft.CheckQuorum(addrs[:4]))
+	assert.NoError(t, cft.CheckQuorumStrict(addrs[:4]))
+	bitmap, _ := cft.AddressesToBitmap(addrs[:4])
+	assert.NoError(t, cft.CheckBitmapQuorum(bitmap))
+	assert.Equal(t, ErrBelowQuorum, bft.CheckBitmapQuorum(bitmap))
+
+	// a weighted majority is enough under CFT
+	assert.NoError(t, cft.CheckWeightedQuorum(addrs[:4]))
+	assert.Equal(t, ErrBelowQuorum, bft.CheckWeightedQuorum(addrs[:4]))
+	assert.Equal(t, uint64(3), cft.WeightedF())
+	assert.Equal(t, uint64(4), cft.WeightedQ())
+
+	// three validators survive a crash
+	assert.NoError(t, NewSetWithFaultModel(addrs[:3], hotstuff.RoundRobin, hotstuff.CFT).HealthCheck())
+	assert.Error(t, NewSetWithFaultModel(addrs[:3], hotstuff.RoundRobin, hotstuff.BFT).HealthCheck())
+}
+
+func TestFixedPolicy(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.Fixed)
+	for _, last := range []common.Address{{}, addrs[0], addrs[3]} {
+		for _, round := range []uint64{0, 1, 2, 100, math.MaxUint64} {
+			valSet.CalcProposer(last, round)
+			assert.Equal(t, addrs[0], valSet.GetProposer().Address())
+		}
+	}
+	assert.Equal(t, []common.Address{addrs[0], addrs[0], addrs[0]}, valSet.ProposerSchedule(addrs[2], 3))
+
+	empty := newDefaultSet(nil, hotstuff.Fixed)
+	empty.CalcProposer(addrs[0], 1)
+	assert.Nil(t, empty.GetProposer())
+}
+
+func TestFilterMembers(t *testing.T) {
+	addrs := testAddresses(6)
+	valSet := newDefaultSet(addrs[:4], hotstuff.RoundRobin)
+
+	members, nonMembers := valSet.FilterMembers([]common.Address{addrs[5], addrs[2], addrs[4], addrs[0], addrs[2]})
+	assert.Equal(t, []common.Address{addrs[2], addrs[0], addrs[2]}, members)
+	assert.Equal(t, []common.Address{addrs[5], addrs[4]}, nonMembers)
+
+	members, nonMembers = valSet.FilterMembers(nil)
+	assert.Empty(t, members)
+	assert.Empty(t, nonMembers)
+}
+
+func TestListOwnership(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+
+	list := valSet.List()
+	list[0] = New(common.HexToAddress("0xdead"))
+	list = append(list[:1], list[2:]...)
+	addrList := valSet.AddressList()
+	addrList[1] = common.HexToAddress("0xbeef")
+
+	assert.Equal(t, addrs, valSet.AddressList())
+	assert.Equal(t, addrs[0], valSet.GetByIndex(0).Address())
+	assert.Equal(t, addrs[1], valSet.GetByIndex(1).Address())
+	assert.NoError(t, valSet.CheckQuorum(addrs))
+}
+
+func TestLastProposer(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	assert.Equal(t, common.Address{}, valSet.LastProposer())
+
+	valSet.SetLastProposer(addrs[1])
+	assert.Equal(t, addrs[1], valSet.LastProposer())
+	for round := uint64(0); round < 6; round++ {
+		valSet.CalcProposerByRound(round)
+		want := valSet.ProposerForRound(addrs[1], round)
+		assert.Equal(t, want, valSet.GetProposer())
+	}
+
+	// the explicit version leaves the stored proposer alone
+	valSet.CalcProposer(addrs[3], 0)
+	assert.Equal(t, addrs[1], valSet.LastProposer())
+	assert.Equal(t, addrs[1], valSet.Copy().LastProposer())
+}
+
+func TestAsMap(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs[:3], hotstuff.RoundRobin)
+
+	validators := valSet.AsMap()
+	assert.Equal(t, 3, len(validators))
+	for _, addr := range addrs[:3] {
+		_, val := valSet.GetByAddress(addr)
+		assert.Equal(t, val, validators[addr])
+	}
+
+	// the map is a snapshot
+	valSet.AddValidator(addrs[3])
+	valSet.RemoveValidator(addrs[0])
+	assert.Equal(t, 3, len(validators))
+	assert.Contains(t, validators, addrs[0])
+	assert.NotContains(t, validators, addrs[3])
+	delete(validators, addrs[1])
+	assert.True(t, valSet.Contains(addrs[1]))
+}
+
+func TestParams(t *testing.T) {
+	for n := 0; n <= 10; n++ {
+		valSet := newDefaultSet(testAddresses(n), hotstuff.RoundRobin)
+		want := hotstuff.ValidatorSetParams{
+			Size:        valSet.Size(),
+			F:           valSet.F(),
+			Q:           valSet.Q(),
+			QuorumSize:  valSet.QuorumSize(),
+			TotalWeight: valSet.TotalWeight(),
+		}
+		assert.Equal(t, want, valSet.Params())
+	}
+	weighted, _ := NewWeightedSet(testAddresses(4), []uint64{1, 2, 3, 4}, hotstuff.RoundRobin)
+	assert.Equal(t, uint64(10), weighted.Params().TotalWeight)
+}
+
+func TestStickyProposer(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.Sticky)
+	for round := uint64(0); round < 3; round++ {
+		valSet.CalcProposer(addrs[1], round)
+		assert.Equal(t, addrs[1], valSet.GetProposer().Address())
+	}
+
+	// a successful commit advances explicitly
+	valSet.AdvanceProposer()
+	assert.Equal(t, addrs[2], valSet.GetProposer().Address())
+	valSet.CalcProposer(valSet.GetProposer().Address(), 2)
+	assert.Equal(t, addrs[2], valSet.GetProposer().Address())
+
+	// a jailed leader does not stall the chain
+	valSet.Jail(addrs[2])
+	valSet.CalcProposer(addrs[2], 3)
+	assert.Equal(t, addrs[3], valSet.GetProposer().Address())
+	valSet.AdvanceProposer()
+	assert.Equal(t, addrs[0], valSet.GetProposer().Address())
+}
+
+// TestStickyRoundChange drives the rounds through CalcProposer the way the
+// core does on every round change, with the same last proposer until a block
+// is committed.
+func TestStickyRoundChange(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.Sticky)
+
+	// a crashed leader keeps stickyRounds rounds, then the next one takes over
+	want := []common.Address{addrs[1], addrs[1], addrs[1], addrs[2], addrs[2], addrs[2], addrs[3], addrs[3], addrs[3], addrs[0]}
+	for round := uint64(0); round < uint64(len(want)); round++ {
+		valSet.CalcProposer(addrs[1], round)
+		assert.Equal(t, want[round], valSet.GetProposer().Address(), "round %d", round)
+	}
+
+	// an explicit advance holds at the next rounds from the same last proposer
+	valSet.CalcProposer(addrs[1], 0)
+	valSet.AdvanceProposer()
+	assert.Equal(t, addrs[2], valSet.GetProposer().Address())
+	assert.Equal(t, uint64(1), valSet.StickyAdvance())
+	for round := uint64(1); round < stickyRounds; round++ {
+		valSet.CalcProposer(addrs[1], round)
+		assert.Equal(t, addrs[2], valSet.GetProposer().Address(), "round %d", round)
+	}
+	valSet.CalcProposer(addrs[1], stickyRounds)
+	assert.Equal(t, addrs[3], valSet.GetProposer().Address())
+	assert.Equal(t, valSet.GetProposer(), valSet.Copy().ProposerForRound(addrs[1], stickyRounds))
+
+	// and is dropped once a block of another last proposer is committed
+	valSet.CalcProposer(addrs[2], 0)
+	assert.Equal(t, addrs[2], valSet.GetProposer().Address())
+	assert.Equal(t, uint64(0), valSet.StickyAdvance())
+}
+
+func TestNewSetUnsorted(t *testing.T) {
+	addrs := testAddresses(5)
+	order := []common.Address{addrs[3], addrs[0], addrs[4], addrs[0], addrs[1]}
+	valSet := NewSetUnsorted(order, hotstuff.RoundRobin)
+
+	// duplicates are dropped, the order is kept
+	want := []common.Address{addrs[3], addrs[0], addrs[4], addrs[1]}
+	assert.Equal(t, want, valSet.AddressList())
+	for i, addr := range want {
+		idx, _ := valSet.GetByAddress(addr)
+		assert.Equal(t, i, idx)
+	}
+
+	// selectors rotate in the given order
+	valSet.CalcProposer(addrs[3], 0)
+	assert.Equal(t, addrs[0], valSet.GetProposer().Address())
+	valSet.CalcProposer(addrs[4], 0)
+	assert.Equal(t, addrs[1], valSet.GetProposer().Address())
+
+	// added validators are appended and copies keep the order
+	assert.True(t, valSet.AddValidator(addrs[2]))
+	want = append(want, addrs[2])
+	assert.Equal(t, want, valSet.AddressList())
+	assert.Equal(t, want, valSet.Copy().AddressList())
+	assert.True(t, valSet.RemoveValidator(addrs[0]))
+	assert.Equal(t, []common.Address{addrs[3], addrs[4], addrs[1], addrs[2]}, valSet.AddressList())
+}
+
+func TestQuorumErrors(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	stranger := common.HexToAddress("0x100")
+
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{newDefaultSet(nil, hotstuff.RoundRobin).CheckQuorum(addrs), ErrEmptySet},
+		{newDefaultSet(nil, hotstuff.RoundRobin).CheckWeightedQuorum(addrs), ErrEmptySet},
+		{valSet.CheckQuorum(addrs[:2]), ErrBelowQuorum},
+		{valSet.CheckQuorumStrict(addrs[:2]), ErrBelowQuorum},
+		{valSet.CheckWeightedQuorum(addrs[:2]), ErrBelowQuorum},
+		{valSet.CheckQuorumStrict([]common.Address{addrs[0], stranger}), ErrNonMember},
+		{valSet.CheckQuorumStrict([]common.Address{addrs[0], addrs[1], addrs[0]}), ErrDuplicateCommitter},
+		{valSet.CheckQuorumStrict(append(addrs, stranger)), ErrTooManyCommitters},
+	}
+	for i, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, tt.err, tt.want)
+		}
+		// existing callers matching the generic error keep working
+		if !errors.Is(tt.err, ErrInvalidParticipant) {
+			t.Errorf("test %d: %v does not wrap %v", i, tt.err, ErrInvalidParticipant)
+		}
+	}
+	// the lenient check still ignores duplicates
+	assert.NoError(t, valSet.CheckQuorum([]common.Address{addrs[0], addrs[1], addrs[0], addrs[2]}))
+}
+
+func TestSetLimit(t *testing.T) {
+	addrs := testAddresses(6)
+	if _, err := NewSetWithLimit(addrs, hotstuff.RoundRobin, 5); !errors.Is(err, ErrSetFull) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrSetFull)
+	}
+
+	valSet, err := NewSetWithLimit(addrs[:3], hotstuff.RoundRobin, 5)
+	if err != nil {
+		t.Fatalf("failed to create set: %v", err)
+	}
+	assert.True(t, valSet.AddValidator(addrs[3]))
+	// the batch stops at the cap
+	assert.Equal(t, 1, valSet.AddValidators(addrs[4:]))
+	assert.False(t, valSet.AddValidator(addrs[5]))
+	assert.Equal(t, 5, valSet.Size())
+	assert.False(t, valSet.Contains(addrs[5]))
+
+	// removing makes room again, and copies keep the cap
+	assert.True(t, valSet.RemoveValidator(addrs[0]))
+	cpy := valSet.Copy()
+	assert.True(t, valSet.AddValidator(addrs[5]))
+	assert.True(t, cpy.AddValidator(addrs[5]))
+	assert.False(t, cpy.AddValidator(addrs[0]))
+
+	unlimited, _ := NewSetWithLimit(addrs, hotstuff.RoundRobin, 0)
+	assert.True(t, unlimited.AddValidator(common.HexToAddress("0x100")))
+
+	// derived sets keep the cap while they fit into it, and drop it otherwise
+	// rather than reporting a cap they already exceed
+	limited, _ := NewSetWithLimit(addrs[:3], hotstuff.RoundRobin, 3)
+	fp := limited.(ConfigFingerprintReader).ConfigFingerprint()
+	uncapped := unlimited.(ConfigFingerprintReader).ConfigFingerprint()
+	subset, _ := limited.Subset(addrs[:2])
+	assert.Equal(t, fp, subset.(ConfigFingerprintReader).ConfigFingerprint())
+	assert.True(t, subset.AddValidator(addrs[2]))
+	assert.False(t, subset.AddValidator(addrs[3]))
+
+	next := limited.Transition(addrs, 1)
+	assert.Equal(t, 6, next.Size())
+	assert.Equal(t, uncapped, next.(ConfigFingerprintReader).ConfigFingerprint())
+	assert.True(t, next.AddValidator(common.HexToAddress("0x100")))
+
+	union := limited.Union(unlimited)
+	assert.Equal(t, 7, union.Size())
+	assert.Equal(t, uncapped, union.(ConfigFingerprintReader).ConfigFingerprint())
+}
+
+func TestIsProposerForRound(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(4), hotstuff.RoundRobin)
+	last := valSet.GetByIndex(1).Address()
+	valSet.CalcProposer(last, 0)
+	current := valSet.GetProposer()
+
+	for round := uint64(0); round < 8; round++ {
+		want := valSet.GetByIndex((2 + round) % 4).Address()
+		for _, val := range valSet.List() {
+			assert.Equal(t, val.Address() == want, valSet.IsProposerForRound(val.Address(), last, round))
+		}
+		assert.False(t, valSet.IsProposerForRound(common.HexToAddress("0x100"), last, round))
+	}
+	// the stored proposer is untouched
+	assert.Equal(t, current, valSet.GetProposer())
+	assert.False(t, newDefaultSet(nil, hotstuff.RoundRobin).IsProposerForRound(common.Address{}, last, 0))
+}
+
+func TestNoRepeatProposer(t *testing.T) {
+	addrs := testAddresses(2)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	// by default every second round lands back on the last proposer
+	assert.Equal(t, addrs[0], valSet.ProposerForRound(addrs[0], 1).Address())
+
+	valSet.SetNoRepeatProposer(true)
+	assert.True(t, valSet.NoRepeatProposer())
+	for round := uint64(0); round < 100; round++ {
+		for _, last := range addrs {
+			if proposer := valSet.ProposerForRound(last, round); proposer.Address() == last {
+				t.Fatalf("round %d: proposer %v repeated", round, last)
+			}
+		}
+	}
+	assert.True(t, valSet.Copy().NoRepeatProposer())
+
+	// the rotation otherwise goes on as usual
+	large := newDefaultSet(testAddresses(5), hotstuff.RoundRobin)
+	large.SetNoRepeatProposer(true)
+	last := large.GetByIndex(2).Address()
+	assert.Equal(t, large.GetByIndex(3), large.ProposerForRound(last, 0))
+	assert.Equal(t, large.GetByIndex(3), large.ProposerForRound(last, 4))
+	assert.Equal(t, large.GetByIndex(4), large.ProposerForRound(last, 6))
+
+	// a lone validator or one left by jailing still proposes
+	single := newDefaultSet(addrs[:1], hotstuff.RoundRobin)
+	single.SetNoRepeatProposer(true)
+	assert.Equal(t, addrs[0], single.ProposerForRound(addrs[0], 3).Address())
+	valSet.Jail(addrs[1])
+	assert.Equal(t, addrs[0], valSet.ProposerForRound(addrs[0], 3).Address())
+}
+
+func TestSingleValidatorQuorum(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	for _, model := range []hotstuff.FaultModel{hotstuff.BFT, hotstuff.CFT} {
+		valSet := NewSetWithFaultModel([]common.Address{addr}, hotstuff.RoundRobin, model)
+		assert.Equal(t, 0, valSet.F(), model)
+		assert.Equal(t, 1, valSet.QuorumSize(), model)
+		assert.True(t, valSet.CanReachQuorum(), model)
+		assert.NoError(t, valSet.CheckQuorum([]common.Address{addr}), model)
+		assert.NoError(t, valSet.CheckQuorumStrict([]common.Address{addr}), model)
+		assert.NoError(t, valSet.CheckWeightedQuorum([]common.Address{addr}), model)
+		assert.ErrorIs(t, valSet.CheckQuorum(nil), ErrBelowQuorum, model)
+		assert.ErrorIs(t, valSet.CheckQuorum([]common.Address{common.HexToAddress("0x2")}), ErrBelowQuorum, model)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+// SetProposerEligible marks a validator as eligible to propose or as
+// vote-only, vote-only validators are skipped by the selectors but still count
+// toward quorum. It returns false if address is not a validator or already has
+// the given eligibility.
+func (valSet *defaultSet) SetProposerEligible(address common.Address, eligible bool) bool {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	idx, ok := valSet.indexes[address]
+	if !ok {
+		return false
+	}
+	old := valSet.validators[idx]
+	if old.ProposerEligible() == eligible {
+		return false
+	}
+	val := cloneValidator(old)
+	val.eligible = eligible
+
+	// validators are shared with copies and detached views, so both the
+	// validator and the slice are replaced rather than mutated
+	validators := make(hotstuff.Validators, len(valSet.validators))
+	copy(validators, valSet.validators)
+	validators[idx] = val
+	valSet.validators = validators
+	if valSet.proposer != nil && valSet.proposer.Address() == address {
+		valSet.proposer = val
+	}
+	return true
+}
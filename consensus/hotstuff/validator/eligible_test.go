@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetProposerEligible(t *testing.T) {
+	addrs := testAddresses(4)
+	policies := []hotstuff.SelectProposerPolicy{hotstuff.RoundRobin, hotstuff.Sticky, hotstuff.VRF, hotstuff.WeightedRoundRobin, hotstuff.Fixed, hotstuff.HashSeeded, hotstuff.Shuffle}
+	for _, policy := range policies {
+		valSet := newDefaultSet(addrs, policy)
+		cpy := valSet.Copy()
+		q := valSet.Q()
+
+		assert.True(t, valSet.SetProposerEligible(addrs[0], false))
+		assert.False(t, valSet.SetProposerEligible(addrs[0], false))
+		assert.False(t, valSet.SetProposerEligible(common.HexToAddress("0x100"), false))
+		_, val := valSet.GetByAddress(addrs[0])
+		assert.False(t, val.ProposerEligible())
+
+		for round := uint64(0); round < 50; round++ {
+			for _, last := range append(addrs, common.Address{}) {
+				valSet.CalcProposer(last, round)
+				if valSet.IsProposer(addrs[0]) {
+					t.Fatalf("%v: vote-only validator selected after %v at round %d", policy, last, round)
+				}
+			}
+		}
+
+		// a vote-only validator still counts toward quorum
+		assert.Equal(t, q, valSet.Q())
+		assert.NoError(t, valSet.CheckQuorum(addrs[:q]))
+		assert.Equal(t, ErrBelowQuorum, valSet.CheckQuorum(addrs[1:q]))
+
+		// the copy taken before is unaffected, one taken now is vote-only too
+		_, val = cpy.GetByAddress(addrs[0])
+		assert.True(t, val.ProposerEligible())
+		_, val = valSet.Copy().GetByAddress(addrs[0])
+		assert.False(t, val.ProposerEligible())
+
+		assert.True(t, valSet.SetProposerEligible(addrs[0], true))
+		_, val = valSet.GetByAddress(addrs[0])
+		assert.True(t, val.ProposerEligible())
+	}
+}
+
+func TestEligibleProposerReplaced(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	valSet.CalcProposer(addrs[0], 0)
+	assert.True(t, valSet.IsProposer(addrs[1]))
+
+	valSet.SetProposerEligible(addrs[1], false)
+	assert.False(t, valSet.GetProposer().ProposerEligible())
+	valSet.CalcProposer(addrs[0], 0)
+	assert.Equal(t, addrs[2], valSet.GetProposer().Address())
+}
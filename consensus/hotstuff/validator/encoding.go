@@ -0,0 +1,327 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+type rlpValidatorSet struct {
+	Policy     uint64
+	Validators []common.Address
+	Weights    []uint64
+	Unsorted   bool `rlp:"optional"`
+}
+
+// EncodeRLP serializes the validator list, the weights and the policy into the
+// Ethereum RLP format. Sets whose order is not the default address order,
+// see NewSetUnsorted, RotateBy and NewSetOrdered, are flagged as unsorted so
+// that the order survives decoding, the flag is omitted otherwise.
+func (valSet *defaultSet) EncodeRLP(w io.Writer) error {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	enc := rlpValidatorSet{
+		Policy:     uint64(valSet.policy),
+		Validators: make([]common.Address, len(valSet.validators)),
+		Weights:    make([]uint64, len(valSet.validators)),
+		Unsorted:   valSet.unsorted || valSet.less != nil,
+	}
+	for i, v := range valSet.validators {
+		enc.Validators[i] = v.Address()
+		enc.Weights[i] = v.Weight()
+	}
+	return rlp.Encode(w, &enc)
+}
+
+// DecodeRLP implements rlp.Decoder, see DecodeSet. The whole state of valSet
+// is replaced by the decoded one.
+func (valSet *defaultSet) DecodeRLP(s *rlp.Stream) error {
+	var dec rlpValidatorSet
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+	set, err := newDecodedSet(dec)
+	if err != nil {
+		return err
+	}
+
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	valSet.replaceWith(set)
+	return nil
+}
+
+// DecodeSet creates a validator set from its RLP encoding, see EncodeRLP. The
+// validators are sorted again unless flagged as unsorted, in which case the
+// set keeps their order like one of NewSetUnsorted, and the proposer is the
+// first validator. The input is untrusted, e.g. the extra-data of a header, so
+// duplicated validators are rejected rather than dropped.
+func DecodeSet(enc []byte) (hotstuff.ValidatorSet, error) {
+	var dec rlpValidatorSet
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		return nil, err
+	}
+	return newDecodedSet(dec)
+}
+
+// newDecodedSet builds a set from its decoded RLP encoding.
+func newDecodedSet(dec rlpValidatorSet) (*defaultSet, error) {
+	if len(dec.Validators) != len(dec.Weights) {
+		return nil, ErrInvalidParticipant
+	}
+	validators, err := decodeValidators(dec.Validators, dec.Weights)
+	if err != nil {
+		return nil, err
+	}
+	policy := hotstuff.SelectProposerPolicy(dec.Policy)
+	if dec.Unsorted {
+		return newUnsortedDefaultSet(validators, policy), nil
+	}
+	return newDefaultSetWithValidators(validators, policy), nil
+}
+
+// checkMember fails if addr is the zero address, is already in seen or has no
+// weight, and adds it to seen otherwise.
+func checkMember(seen map[common.Address]struct{}, addr common.Address, weight uint64) error {
+	if emptyAddress(addr) {
+		return ErrZeroAddress
+	}
+	if _, ok := seen[addr]; ok {
+		return fmt.Errorf("%w: %s listed twice", ErrInvalidParticipant, addr.Hex())
+	}
+	seen[addr] = struct{}{}
+	if weight == 0 {
+		return fmt.Errorf("%w: %s has no weight", ErrInvalidParticipant, addr.Hex())
+	}
+	return nil
+}
+
+// replaceWith replaces the whole state of valSet with the one of set, a fresh
+// set built from decoded input, so that nothing of the previous content, e.g.
+// its order, jailed validators, cap or epoch, survives decoding. The metrics
+// registry and the membership subscribers are kept. It should be called with
+// the write lock held.
+func (valSet *defaultSet) replaceWith(set *defaultSet) {
+	valSet.validators = set.validators
+	valSet.policy = set.policy
+	valSet.less = set.less
+	valSet.unsorted = set.unsorted
+	valSet.maxSize = set.maxSize
+	valSet.faultModel = set.faultModel
+	valSet.proposer = set.proposer
+	valSet.lastProposer = set.lastProposer
+	valSet.selector = set.selector
+	valSet.genesisProposer = set.genesisProposer
+	valSet.noRepeatProposer = set.noRepeatProposer
+	valSet.stickyBasis = set.stickyBasis
+	valSet.stickyAdvance = set.stickyAdvance
+	valSet.vrf = set.vrf
+	valSet.vrfOutput = set.vrfOutput
+	valSet.seed = set.seed
+	valSet.epochSeed = set.epochSeed
+	valSet.jailed = set.jailed
+	valSet.history = set.history
+	valSet.epoch = set.epoch
+	valSet.added = set.added
+	valSet.removed = set.removed
+	valSet.refresh()
+}
+
+// decodeValidators creates the validators of decoded addresses and weights,
+// all of weight 1 if weights is nil. It checks the input like NewWeightedSet
+// and Builder do: the zero address, a duplicated address, a zero weight and an
+// overflowing total weight are rejected.
+func decodeValidators(addrs []common.Address, weights []uint64) (hotstuff.Validators, error) {
+	validators := make(hotstuff.Validators, len(addrs))
+	seen := make(map[common.Address]struct{}, len(addrs))
+	for i, addr := range addrs {
+		weight := uint64(1)
+		if weights != nil {
+			weight = weights[i]
+		}
+		if err := checkMember(seen, addr, weight); err != nil {
+			return nil, err
+		}
+		validators[i] = NewWithWeight(addr, weight)
+	}
+	if err := checkTotalWeight(validators); err != nil {
+		return nil, err
+	}
+	return validators, nil
+}
+
+// Hash returns the keccak256 hash of the RLP encoded policy and validator
+// list, the weights are only committed to if any validator is not of weight 1.
+// Light clients may use it as a compact commitment to the set, and nodes
+// running different policies get different hashes.
+func (valSet *defaultSet) Hash() common.Hash {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	addrs := make([]common.Address, len(valSet.validators))
+	weights := make([]uint64, len(valSet.validators))
+	weighted := false
+	for i, v := range valSet.validators {
+		addrs[i] = v.Address()
+		weights[i] = v.Weight()
+		weighted = weighted || v.Weight() != 1
+	}
+	var (
+		enc []byte
+		err error
+	)
+	policy := uint64(valSet.policy)
+	if weighted {
+		enc, err = rlp.EncodeToBytes([]interface{}{policy, addrs, weights})
+	} else {
+		enc, err = rlp.EncodeToBytes([]interface{}{policy, addrs})
+	}
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode validators: %v", err))
+	}
+	return crypto.Keccak256Hash(enc)
+}
+
+// KeyReader is implemented by validator sets which can key a map, see Key.
+type KeyReader interface {
+	Key() [32]byte
+}
+
+// Key returns Hash as an array usable directly as a map key, e.g. to cache
+// data derived from a set. Sets holding the same validators in the same order,
+// with the same weights and under the same policy, have the same key whatever
+// their proposer, jailed or history state.
+func (valSet *defaultSet) Key() [32]byte {
+	return valSet.Hash()
+}
+
+// ConfigFingerprintReader is implemented by validator sets which commit to
+// their consensus relevant configuration, see ConfigFingerprint.
+type ConfigFingerprintReader interface {
+	ConfigFingerprint() common.Hash
+}
+
+// ConfigFingerprint returns the keccak256 hash of the RLP encoded consensus
+// relevant configuration: the policy, the fault model, the maximum size, the
+// genesis proposer and the no repeat option. Nodes can gossip and compare it
+// to detect misconfiguration early. Custom orders and selectors are code and
+// are not covered.
+func (valSet *defaultSet) ConfigFingerprint() common.Hash {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	enc, err := rlp.EncodeToBytes([]interface{}{
+		uint64(valSet.policy),
+		uint64(valSet.faultModel),
+		uint64(valSet.maxSize),
+		valSet.genesisProposer,
+		valSet.noRepeatProposer,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode config: %v", err))
+	}
+	return crypto.Keccak256Hash(enc)
+}
+
+type jsonValidatorSet struct {
+	Policy     string           `json:"policy"`
+	Proposer   *common.Address  `json:"proposer,omitempty"`
+	Validators []common.Address `json:"validators"`
+	Weights    []uint64         `json:"weights,omitempty"`
+	Unsorted   bool             `json:"unsorted,omitempty"`
+}
+
+// MarshalJSON dumps the validators, the policy name and the current proposer.
+// Weights are only emitted if any validator is not of weight 1, and sets are
+// flagged as unsorted as by EncodeRLP.
+func (valSet *defaultSet) MarshalJSON() ([]byte, error) {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	if _, err := hotstuff.ParsePolicy(valSet.policy.String()); err != nil {
+		return nil, fmt.Errorf("unknown proposer policy %d", uint64(valSet.policy))
+	}
+	enc := jsonValidatorSet{
+		Policy:     valSet.policy.String(),
+		Validators: make([]common.Address, len(valSet.validators)),
+		Unsorted:   valSet.unsorted || valSet.less != nil,
+	}
+	weighted := false
+	weights := make([]uint64, len(valSet.validators))
+	for i, v := range valSet.validators {
+		enc.Validators[i] = v.Address()
+		weights[i] = v.Weight()
+		weighted = weighted || v.Weight() != 1
+	}
+	if weighted {
+		enc.Weights = weights
+	}
+	if valSet.proposer != nil {
+		proposer := valSet.proposer.Address()
+		enc.Proposer = &proposer
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON rebuilds the validator set, the proposer must be a member.
+// Validators flagged as unsorted keep their order as for DecodeRLP.
+func (valSet *defaultSet) UnmarshalJSON(input []byte) error {
+	var dec jsonValidatorSet
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	policy, err := hotstuff.ParsePolicy(dec.Policy)
+	if err != nil {
+		return err
+	}
+	if dec.Weights != nil && len(dec.Weights) != len(dec.Validators) {
+		return ErrInvalidParticipant
+	}
+
+	validators, err := decodeValidators(dec.Validators, dec.Weights)
+	if err != nil {
+		return err
+	}
+	set := newDefaultSetWithValidators(validators, policy)
+	if dec.Unsorted {
+		set = newUnsortedDefaultSet(validators, policy)
+	}
+	if dec.Proposer != nil {
+		idx, ok := set.indexes[*dec.Proposer]
+		if !ok {
+			return ErrInvalidParticipant
+		}
+		set.proposer = set.validators[idx]
+	}
+
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	valSet.replaceWith(set)
+	return nil
+}
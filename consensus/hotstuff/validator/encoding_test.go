@@ -0,0 +1,375 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatorSetRLP(t *testing.T) {
+	addrs := make([]common.Address, 21)
+	for i := range addrs {
+		key, _ := crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	valSet := newDefaultSet(addrs, hotstuff.Sticky)
+
+	enc, err := rlp.EncodeToBytes(valSet)
+	if err != nil {
+		t.Fatalf("failed to encode validator set: %v", err)
+	}
+	dec, err := DecodeSet(enc)
+	if err != nil {
+		t.Fatalf("failed to decode validator set: %v", err)
+	}
+
+	assert.True(t, valSet.Cmp(dec))
+	assert.Equal(t, valSet.AddressList(), dec.AddressList())
+	assert.Equal(t, valSet.Policy(), dec.Policy())
+	assert.Equal(t, valSet.GetByIndex(0), dec.GetProposer())
+
+	// selection must behave identically after a round trip
+	for round := uint64(0); round < 30; round++ {
+		valSet.CalcProposer(addrs[0], round)
+		dec.CalcProposer(addrs[0], round)
+		assert.Equal(t, valSet.GetProposer(), dec.GetProposer())
+	}
+	// and the decoded set is complete, e.g. it keeps a proposer history
+	assert.Equal(t, valSet.RecentProposers(5), dec.(RecentProposersReader).RecentProposers(5))
+	assert.Len(t, dec.(RecentProposersReader).RecentProposers(5), 5)
+
+	_, err = DecodeSet(enc[:len(enc)-1])
+	assert.Error(t, err)
+	enc, _ = rlp.EncodeToBytes(rlpValidatorSet{Validators: addrs[:2], Weights: []uint64{1, 0}})
+	_, err = DecodeSet(enc)
+	assert.ErrorIs(t, err, ErrInvalidParticipant)
+}
+
+func TestWeightedValidatorSetRLP(t *testing.T) {
+	valSet, _ := NewWeightedSet(testAddresses(3), []uint64{5, 1, 7}, hotstuff.RoundRobin)
+	enc, err := rlp.EncodeToBytes(valSet)
+	if err != nil {
+		t.Fatalf("failed to encode validator set: %v", err)
+	}
+	dec, err := DecodeSet(enc)
+	if err != nil {
+		t.Fatalf("failed to decode validator set: %v", err)
+	}
+	for i, v := range valSet.List() {
+		assert.Equal(t, v.Weight(), dec.GetByIndex(uint64(i)).Weight())
+	}
+}
+
+func TestUnsortedValidatorSetRLP(t *testing.T) {
+	addrs := testAddresses(5)
+	unsorted := NewSetUnsorted([]common.Address{addrs[3], addrs[0], addrs[4], addrs[1], addrs[2]}, hotstuff.RoundRobin)
+	rotated := newDefaultSet(addrs, hotstuff.Sticky)
+	rotated.RotateBy(2)
+	descending := NewSetOrdered(addrs, hotstuff.RoundRobin, func(a, b common.Address) bool {
+		return a.Hex() > b.Hex()
+	})
+
+	// the order, hence the hash and the selection, survives a round trip
+	for i, valSet := range []hotstuff.ValidatorSet{unsorted, rotated, descending} {
+		enc, err := rlp.EncodeToBytes(valSet)
+		if err != nil {
+			t.Fatalf("test %d: failed to encode validator set: %v", i, err)
+		}
+		dec := new(defaultSet)
+		if err := rlp.DecodeBytes(enc, dec); err != nil {
+			t.Fatalf("test %d: failed to decode validator set: %v", i, err)
+		}
+		assert.Equal(t, valSet.AddressList(), dec.AddressList(), "test %d", i)
+		assert.Equal(t, valSet.Hash(), dec.Hash(), "test %d", i)
+		assert.Equal(t, valSet.ProposerSchedule(addrs[0], 10), dec.ProposerSchedule(addrs[0], 10), "test %d", i)
+
+		// validators added later are appended like to an unsorted set
+		assert.True(t, dec.AddValidator(common.HexToAddress("0xff")), "test %d", i)
+		assert.Equal(t, common.HexToAddress("0xff"), dec.GetByIndex(5).Address(), "test %d", i)
+	}
+
+	// sets in the default order are encoded without the flag
+	sorted := newDefaultSet(addrs, hotstuff.RoundRobin)
+	enc, _ := rlp.EncodeToBytes(sorted)
+	want, _ := rlp.EncodeToBytes([]interface{}{uint64(hotstuff.RoundRobin), addrs, []uint64{1, 1, 1, 1, 1}})
+	assert.Equal(t, want, enc)
+}
+
+func TestValidatorSetJSON(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.Sticky)
+	valSet.CalcProposer(addrs[2], 1)
+
+	blob, err := json.Marshal(valSet)
+	if err != nil {
+		t.Fatalf("failed to marshal validator set: %v", err)
+	}
+	assert.Contains(t, string(blob), `"policy":"sticky"`)
+	assert.Contains(t, string(blob), `"proposer":"`+strings.ToLower(addrs[2].Hex())+`"`)
+	assert.NotContains(t, string(blob), `"weights"`)
+
+	dec := new(defaultSet)
+	if err := json.Unmarshal(blob, dec); err != nil {
+		t.Fatalf("failed to unmarshal validator set: %v", err)
+	}
+	assert.Equal(t, valSet.AddressList(), dec.AddressList())
+	assert.Equal(t, valSet.Policy(), dec.Policy())
+	assert.Equal(t, valSet.GetProposer(), dec.GetProposer())
+	assertSelectorConsistent(t, valSet, dec, 10)
+
+	weighted, _ := NewWeightedSet(addrs, []uint64{1, 2, 3, 4}, hotstuff.VRF)
+	blob, _ = json.Marshal(weighted)
+	dec = new(defaultSet)
+	if err := json.Unmarshal(blob, dec); err != nil {
+		t.Fatalf("failed to unmarshal validator set: %v", err)
+	}
+	assert.Equal(t, uint64(3), dec.GetByIndex(2).Weight())
+	assert.Equal(t, hotstuff.VRF, dec.Policy())
+	assertSelectorConsistent(t, weighted, dec, 10)
+
+	// as is the order of unsorted sets
+	unsorted := NewSetUnsorted([]common.Address{addrs[2], addrs[0], addrs[3], addrs[1]}, hotstuff.RoundRobin)
+	blob, _ = json.Marshal(unsorted)
+	assert.Contains(t, string(blob), `"unsorted":true`)
+	dec = new(defaultSet)
+	if err := json.Unmarshal(blob, dec); err != nil {
+		t.Fatalf("failed to unmarshal validator set: %v", err)
+	}
+	assert.True(t, unsorted.Equal(dec))
+	assert.Equal(t, unsorted.Hash(), dec.Hash())
+	assertSelectorConsistent(t, unsorted, dec, 10)
+
+	// the proposer must be a member of the set
+	bad := `{"policy":"roundRobin","proposer":"0x0000000000000000000000000000000000000009","validators":["0x0000000000000000000000000000000000000001"]}`
+	assert.Equal(t, ErrInvalidParticipant, json.Unmarshal([]byte(bad), new(defaultSet)))
+	bad = `{"policy":"random","validators":[]}`
+	assert.Error(t, json.Unmarshal([]byte(bad), new(defaultSet)))
+}
+
+func TestDecodeReplacesState(t *testing.T) {
+	addrs := testAddresses(5)
+	descending := NewSetOrdered(addrs[:3], hotstuff.Sticky, func(a, b common.Address) bool {
+		return a.Hex() > b.Hex()
+	})
+	descending.Jail(addrs[1])
+	limited, _ := NewSetWithLimit(addrs[:3], hotstuff.RoundRobin, 3)
+	cft := NewSetWithFaultModel(addrs[:3], hotstuff.RoundRobin, hotstuff.CFT)
+	epoch := newDefaultSet(addrs[:3], hotstuff.RoundRobin).Transition(addrs[:3], 5)
+
+	src := newDefaultSet(addrs[:3], hotstuff.RoundRobin)
+	blob, _ := json.Marshal(src)
+	enc, _ := rlp.EncodeToBytes(src)
+	decoders := map[string]func(hotstuff.ValidatorSet) error{
+		"json": func(valSet hotstuff.ValidatorSet) error { return json.Unmarshal(blob, valSet) },
+		"rlp":  func(valSet hotstuff.ValidatorSet) error { return rlp.DecodeBytes(enc, valSet) },
+	}
+	for name, decode := range decoders {
+		for i, valSet := range []hotstuff.ValidatorSet{descending.Copy(), limited.Copy(), cft.Copy(), epoch.Copy()} {
+			if err := decode(valSet); err != nil {
+				t.Fatalf("%s %d: failed to decode validator set: %v", name, i, err)
+			}
+			// nothing of the previous content survives
+			assert.True(t, src.Equal(valSet), "%s %d", name, i)
+			assert.False(t, valSet.IsJailed(addrs[1]), "%s %d", name, i)
+			assert.Equal(t, hotstuff.BFT, valSet.FaultModel(), "%s %d", name, i)
+			assert.Equal(t, uint64(0), valSet.Epoch(), "%s %d", name, i)
+			assert.Equal(t, 2, valSet.AddValidators([]common.Address{addrs[4], addrs[3]}), "%s %d", name, i)
+			assert.Equal(t, addrs, valSet.AddressList(), "%s %d", name, i)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, policy := range []hotstuff.SelectProposerPolicy{hotstuff.RoundRobin, hotstuff.Sticky, hotstuff.VRF, hotstuff.WeightedRoundRobin, hotstuff.Fixed, hotstuff.HashSeeded, hotstuff.Shuffle} {
+		parsed, err := hotstuff.ParsePolicy(policy.String())
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", policy.String(), err)
+		}
+		assert.Equal(t, policy, parsed)
+	}
+	parsed, err := hotstuff.ParsePolicy("Sticky")
+	assert.NoError(t, err)
+	assert.Equal(t, hotstuff.Sticky, parsed)
+
+	for _, name := range []string{"", "random", "0"} {
+		if _, err := hotstuff.ParsePolicy(name); err == nil {
+			t.Errorf("policy %q accepted", name)
+		}
+	}
+	assert.Equal(t, "SelectProposerPolicy(42)", hotstuff.SelectProposerPolicy(42).String())
+}
+
+func TestValidatorSetHash(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs[:3], hotstuff.RoundRobin)
+	hash := valSet.Hash()
+
+	// deterministic, stable under copy and independent of the input order
+	assert.Equal(t, hash, valSet.Copy().Hash())
+	assert.Equal(t, hash, newDefaultSet([]common.Address{addrs[2], addrs[0], addrs[1]}, hotstuff.RoundRobin).Hash())
+
+	enc, _ := rlp.EncodeToBytes([]interface{}{uint64(hotstuff.RoundRobin), valSet.AddressList()})
+	assert.Equal(t, crypto.Keccak256Hash(enc), hash)
+
+	valSet.AddValidator(addrs[3])
+	assert.NotEqual(t, hash, valSet.Hash())
+	valSet.RemoveValidator(addrs[3])
+	assert.Equal(t, hash, valSet.Hash())
+
+	// weights are part of the commitment
+	weighted, _ := NewWeightedSet(addrs[:3], []uint64{1, 1, 2}, hotstuff.RoundRobin)
+	assert.NotEqual(t, hash, weighted.Hash())
+	unit, _ := NewWeightedSet(addrs[:3], []uint64{1, 1, 1}, hotstuff.RoundRobin)
+	assert.Equal(t, hash, unit.Hash())
+
+	// so is the policy
+	assert.NotEqual(t, hash, newDefaultSet(addrs[:3], hotstuff.Sticky).Hash())
+}
+
+func TestValidatorSetKey(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	assert.Equal(t, [32]byte(valSet.Hash()), valSet.Key())
+
+	cache := map[[32]byte]string{valSet.Key(): "epoch 1"}
+
+	// a copy, even one moved on to another proposer, hits the same entry
+	cpy := valSet.Copy()
+	cpy.CalcProposerByIndex(2)
+	if have, ok := cache[cpy.(KeyReader).Key()]; !ok || have != "epoch 1" {
+		t.Errorf("cache lookup by copy mismatch: have %q, %v, want %q", have, ok, "epoch 1")
+	}
+
+	// so do the read only sets handed out by a SetCache
+	sets := NewSetCache(1)
+	sets.Put(1, cpy)
+	cached, _ := sets.Get(1)
+	if have, ok := cache[cached.(KeyReader).Key()]; !ok || have != "epoch 1" {
+		t.Errorf("cache lookup by cached set mismatch: have %q, %v, want %q", have, ok, "epoch 1")
+	}
+
+	cpy.RemoveValidator(addrs[0])
+	if _, ok := cache[cpy.(KeyReader).Key()]; ok {
+		t.Errorf("cache hit for a set with different members")
+	}
+	if _, ok := cache[newDefaultSet(addrs, hotstuff.Sticky).Key()]; ok {
+		t.Errorf("cache hit for a set with a different policy")
+	}
+}
+
+func TestConfigFingerprint(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	fingerprint := valSet.ConfigFingerprint()
+
+	// independent of the membership and carried by copies
+	assert.Equal(t, fingerprint, newDefaultSet(addrs[:2], hotstuff.RoundRobin).ConfigFingerprint())
+	assert.Equal(t, fingerprint, valSet.Copy().(ConfigFingerprintReader).ConfigFingerprint())
+
+	limited, _ := NewSetWithLimit(addrs, hotstuff.RoundRobin, 10)
+	genesis := newDefaultSet(addrs, hotstuff.RoundRobin)
+	genesis.SetGenesisProposer(addrs[1])
+	noRepeat := newDefaultSet(addrs, hotstuff.RoundRobin)
+	noRepeat.SetNoRepeatProposer(true)
+	others := []hotstuff.ValidatorSet{
+		newDefaultSet(addrs, hotstuff.Sticky),
+		NewSetWithFaultModel(addrs, hotstuff.RoundRobin, hotstuff.CFT),
+		limited,
+		genesis,
+		noRepeat,
+	}
+	seen := map[common.Hash]int{fingerprint: -1}
+	for i, other := range others {
+		have := other.(ConfigFingerprintReader).ConfigFingerprint()
+		if j, ok := seen[have]; ok {
+			t.Errorf("config %d: fingerprint collides with config %d", i, j)
+		}
+		seen[have] = i
+	}
+}
+
+func TestDecodeWeightOverflow(t *testing.T) {
+	addrs := testAddresses(2)
+	var dec defaultSet
+	enc, _ := rlp.EncodeToBytes(rlpValidatorSet{Validators: addrs, Weights: []uint64{1 << 63, 1 << 63}})
+	if err := rlp.DecodeBytes(enc, &dec); !errors.Is(err, ErrWeightOverflow) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrWeightOverflow)
+	}
+	input := fmt.Sprintf(`{"policy":"roundRobin","validators":["%s","%s"],"weights":[%d,1]}`, addrs[0].Hex(), addrs[1].Hex(), uint64(math.MaxUint64))
+	if err := json.Unmarshal([]byte(input), &dec); !errors.Is(err, ErrWeightOverflow) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrWeightOverflow)
+	}
+}
+
+func TestDecodeInvalidValidators(t *testing.T) {
+	addrs := testAddresses(2)
+	testCases := []struct {
+		addrs   []common.Address
+		weights []uint64
+	}{
+		// a duplicate must not inflate the size, F and Q
+		{[]common.Address{addrs[0], addrs[0], addrs[1]}, []uint64{1, 1, 1}},
+		{addrs, []uint64{1, 0}},
+	}
+	for i, test := range testCases {
+		var dec defaultSet
+		enc, _ := rlp.EncodeToBytes(rlpValidatorSet{Validators: test.addrs, Weights: test.weights})
+		if err := rlp.DecodeBytes(enc, &dec); !errors.Is(err, ErrInvalidParticipant) {
+			t.Errorf("test %d: rlp error mismatch: have %v, want %v", i, err, ErrInvalidParticipant)
+		}
+		input, _ := json.Marshal(jsonValidatorSet{Policy: "roundRobin", Validators: test.addrs, Weights: test.weights})
+		if err := json.Unmarshal(input, &dec); !errors.Is(err, ErrInvalidParticipant) {
+			t.Errorf("test %d: json error mismatch: have %v, want %v", i, err, ErrInvalidParticipant)
+		}
+		assert.Equal(t, 0, dec.Size(), "test %d", i)
+	}
+
+	// decoded sets are ordered like the ones created directly
+	var dec defaultSet
+	enc, _ := rlp.EncodeToBytes(rlpValidatorSet{Validators: []common.Address{addrs[1], addrs[0]}, Weights: []uint64{2, 1}})
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		t.Fatalf("failed to decode validator set: %v", err)
+	}
+	want, _ := NewWeightedSet([]common.Address{addrs[1], addrs[0]}, []uint64{2, 1}, hotstuff.RoundRobin)
+	assert.True(t, want.Equal(&dec))
+	assert.Equal(t, want.Hash(), dec.Hash())
+}
+
+func TestDecodeZeroAddress(t *testing.T) {
+	var dec defaultSet
+	enc, _ := rlp.EncodeToBytes(rlpValidatorSet{Validators: []common.Address{{}, testAddresses(1)[0]}, Weights: []uint64{1, 1}})
+	if err := rlp.DecodeBytes(enc, &dec); !errors.Is(err, ErrZeroAddress) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrZeroAddress)
+	}
+	input := `{"policy":"roundRobin","validators":["0x0000000000000000000000000000000000000000"]}`
+	if err := json.Unmarshal([]byte(input), &dec); !errors.Is(err, ErrZeroAddress) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrZeroAddress)
+	}
+}
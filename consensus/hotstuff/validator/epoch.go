@@ -0,0 +1,80 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+func (valSet *defaultSet) Epoch() uint64 {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.epoch
+}
+
+// Transition creates the validator set of the next epoch. The policy, the
+// selector and the vrf hooks are carried forward, surviving validators keep
+// their weight and the proposer is reset to the first validator. The cap is
+// dropped if the new validators exceed it.
+func (valSet *defaultSet) Transition(newAddrs []common.Address, epoch uint64) hotstuff.ValidatorSet {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	validators := make(hotstuff.Validators, len(newAddrs))
+	for i, addr := range newAddrs {
+		if idx, ok := valSet.indexes[addr]; ok {
+			validators[i] = valSet.validators[idx]
+		} else {
+			validators[i] = New(addr)
+		}
+	}
+	next := valSet.newLike(validators)
+	next.selector = valSet.selector
+	next.vrf = valSet.vrf
+	next.vrfOutput = common.CopyBytes(valSet.vrfOutput)
+	next.seed = valSet.seed
+	next.history = newProposerHistory(valSet.history.size())
+	next.lastProposer = valSet.lastProposer
+	next.epoch = epoch
+
+	old := make([]common.Address, len(valSet.validators))
+	for i, v := range valSet.validators {
+		old[i] = v.Address()
+	}
+	next.added, next.removed = diffAddresses(old, next.AddressList())
+	return next
+}
+
+// EpochChanges returns the validators joined and left when the set was created
+// by Transition.
+func (valSet *defaultSet) EpochChanges() (added, removed []common.Address) {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return copyAddresses(valSet.added), copyAddresses(valSet.removed)
+}
+
+func copyAddresses(addrs []common.Address) []common.Address {
+	if addrs == nil {
+		return nil
+	}
+	cpy := make([]common.Address, len(addrs))
+	copy(cpy, addrs)
+	return cpy
+}
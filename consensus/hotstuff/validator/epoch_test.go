@@ -0,0 +1,50 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransition(t *testing.T) {
+	addrs := testAddresses(4)
+	a, b, c, d := addrs[0], addrs[1], addrs[2], addrs[3]
+
+	valSet := newDefaultSet([]common.Address{a, b, c}, hotstuff.Sticky)
+	valSet.CalcProposer(b, 1)
+	assert.Equal(t, uint64(0), valSet.Epoch())
+
+	next := valSet.Transition([]common.Address{d, c, b}, 1)
+	assert.Equal(t, uint64(1), next.Epoch())
+	assert.Equal(t, hotstuff.Sticky, next.Policy())
+	assert.Equal(t, []common.Address{b, c, d}, next.AddressList())
+	assert.Equal(t, b, next.GetProposer().Address())
+
+	added, removed := next.EpochChanges()
+	assert.Equal(t, []common.Address{d}, added)
+	assert.Equal(t, []common.Address{a}, removed)
+
+	// the source set is left untouched
+	assert.Equal(t, []common.Address{a, b, c}, valSet.AddressList())
+	assert.Equal(t, uint64(0), valSet.Epoch())
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// membershipEventBuffer is the channel capacity of each membership subscriber,
+// events are dropped once a subscriber falls that far behind.
+const membershipEventBuffer = 16
+
+// MembershipEvent is posted when a validator is added to or removed from the
+// set.
+type MembershipEvent struct {
+	Address common.Address
+	Added   bool // true if the validator joined, false if it left
+	Size    int  // the size of the set after the change
+}
+
+// MembershipNotifier is implemented by validator sets which post
+// MembershipEvents.
+type MembershipNotifier interface {
+	SubscribeMembershipChange() (<-chan MembershipEvent, func())
+}
+
+// SubscribeMembershipChange registers a subscriber for membership events. The
+// delivery never blocks the set: events are dropped if the subscriber's
+// buffer is full. The returned function cancels the subscription and closes
+// the channel.
+func (valSet *defaultSet) SubscribeMembershipChange() (<-chan MembershipEvent, func()) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	if valSet.subscribers == nil {
+		valSet.subscribers = make(map[uint64]chan MembershipEvent)
+	}
+	id := valSet.nextSubscriber
+	valSet.nextSubscriber++
+	ch := make(chan MembershipEvent, membershipEventBuffer)
+	valSet.subscribers[id] = ch
+
+	unsubscribe := func() {
+		valSet.validatorMu.Lock()
+		defer valSet.validatorMu.Unlock()
+		if _, ok := valSet.subscribers[id]; ok {
+			delete(valSet.subscribers, id)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// notifyMembership posts a membership event to every subscriber without
+// blocking, it should be called with the write lock held.
+func (valSet *defaultSet) notifyMembership(address common.Address, added bool) {
+	ev := MembershipEvent{Address: address, Added: added, Size: len(valSet.validators)}
+	for _, ch := range valSet.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembershipEvents(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(3), hotstuff.RoundRobin)
+	events, unsubscribe := valSet.SubscribeMembershipChange()
+
+	added := common.HexToAddress("0x100")
+	assert.True(t, valSet.AddValidator(added))
+	assert.False(t, valSet.AddValidator(added))
+	assert.True(t, valSet.RemoveValidator(added))
+	assert.False(t, valSet.RemoveValidator(added))
+
+	assert.Equal(t, MembershipEvent{Address: added, Added: true, Size: 4}, <-events)
+	assert.Equal(t, MembershipEvent{Address: added, Added: false, Size: 3}, <-events)
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %v", ev)
+	default:
+	}
+
+	// a slow subscriber never blocks the set
+	for i := 0; i < 2*membershipEventBuffer; i++ {
+		valSet.AddValidator(common.HexToAddress("0x200"))
+		valSet.RemoveValidator(common.HexToAddress("0x200"))
+	}
+	assert.Equal(t, membershipEventBuffer, len(events))
+
+	unsubscribe()
+	unsubscribe()
+	for range events {
+	}
+	valSet.AddValidator(added)
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+// CheckProposerFairness walks the proposer schedule of the given rounds
+// starting at start and verifies the guarantee of rotating policies such as
+// round robin: every proposer is a member and no validator which may propose
+// is given fewer than rounds/Size() slots. The window is clamped so that
+// start+rounds does not overflow.
+func CheckProposerFairness(valSet hotstuff.ValidatorSet, lastProposer common.Address, start, rounds uint64) error {
+	size := uint64(valSet.Size())
+	if size == 0 {
+		return nil
+	}
+	if rounds > math.MaxUint64-start {
+		rounds = math.MaxUint64 - start
+	}
+
+	slots := make(map[common.Address]uint64, size)
+	for i := uint64(0); i < rounds; i++ {
+		round := start + i
+		proposer := valSet.ProposerForRound(lastProposer, round)
+		if proposer == nil {
+			return fmt.Errorf("no proposer for round %d", round)
+		}
+		if !valSet.Contains(proposer.Address()) {
+			return fmt.Errorf("non-member proposer %s for round %d", proposer.Address().Hex(), round)
+		}
+		slots[proposer.Address()]++
+	}
+
+	want := rounds / size
+	for _, val := range valSet.List() {
+		if !canPropose(valSet, val) {
+			continue
+		}
+		if have := slots[val.Address()]; have < want {
+			return fmt.Errorf("validator %s starved: have %d slots, want at least %d", val.Address().Hex(), have, want)
+		}
+	}
+	return nil
+}
+
+// FairnessScorer is implemented by validator sets which measure the fairness
+// of a proposer schedule, see FairnessScore.
+type FairnessScorer interface {
+	FairnessScore(schedule []common.Address) float64
+}
+
+// FairnessScore measures how far the proposer counts of schedule, e.g. the
+// proposers of the last rounds or the output of ProposerSchedule, are from a
+// uniform spread over the validators which may propose. It is the chi-square
+// statistic of the counts divided by its maximum, so it ranges from 0, every
+// validator proposed equally often, to 1, a single validator proposed every
+// round. Slots taken by non-members or ineligible validators count as missing
+// from the eligible ones. Sets with fewer than two eligible validators and
+// empty schedules score 0.
+func (valSet *defaultSet) FairnessScore(schedule []common.Address) float64 {
+	return fairnessScore(valSet, schedule)
+}
+
+// fairnessScore implements FairnessScore on top of the ValidatorSet
+// interface, so that wrapping sets share it.
+func fairnessScore(valSet hotstuff.ValidatorSet, schedule []common.Address) float64 {
+	counts := make(map[common.Address]int)
+	for _, val := range valSet.List() {
+		if canPropose(valSet, val) {
+			counts[val.Address()] = 0
+		}
+	}
+	k := len(counts)
+	if k < 2 || len(schedule) == 0 {
+		return 0
+	}
+	for _, addr := range schedule {
+		if _, ok := counts[addr]; ok {
+			counts[addr]++
+		}
+	}
+
+	n := float64(len(schedule))
+	expected := n / float64(k)
+	chi := 0.0
+	for _, count := range counts {
+		d := float64(count) - expected
+		chi += d * d / expected
+	}
+	// a single validator taking all n slots gives the largest value, n*(k-1)
+	return math.Min(chi/(n*float64(k-1)), 1)
+}
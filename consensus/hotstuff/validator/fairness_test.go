@@ -0,0 +1,143 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+func TestCheckProposerFairness(t *testing.T) {
+	addrs := testAddresses(7)
+	for _, policy := range []hotstuff.SelectProposerPolicy{hotstuff.RoundRobin, hotstuff.Shuffle} {
+		valSet := newDefaultSet(addrs, policy)
+		for _, last := range []common.Address{{}, addrs[3], common.HexToAddress("0xff")} {
+			for _, start := range []uint64{0, 1000, math.MaxUint64 - 10} {
+				if err := CheckProposerFairness(valSet, last, start, 100); err != nil {
+					t.Errorf("policy %v, last %x, start %d: %v", policy, last, start, err)
+				}
+			}
+		}
+		valSet.Jail(addrs[2])
+		if err := CheckProposerFairness(valSet, addrs[0], 0, 100); err != nil {
+			t.Errorf("policy %v with jailed validator: %v", policy, err)
+		}
+	}
+
+	// a selector which always favours the first validator starves the others
+	starving := NewSetWithSelector(addrs, hotstuff.RoundRobin, func(valSet hotstuff.ValidatorSet, _ common.Address, _ uint64) hotstuff.Validator {
+		return valSet.GetByIndex(0)
+	})
+	if err := CheckProposerFairness(starving, addrs[0], 0, 100); err == nil {
+		t.Errorf("starving selector passed the fairness check")
+	}
+}
+
+func TestFairnessScore(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+
+	if score := valSet.FairnessScore(valSet.ProposerSchedule(common.Address{}, 40)); score != 0 {
+		t.Errorf("round robin score mismatch: have %v, want 0", score)
+	}
+	monopoly := make([]common.Address, 40)
+	for i := range monopoly {
+		monopoly[i] = addrs[1]
+	}
+	if score := valSet.FairnessScore(monopoly); score != 1 {
+		t.Errorf("monopoly score mismatch: have %v, want 1", score)
+	}
+
+	// counts 3, 15, 3 and 3 over 24 rounds: chi-square 18 out of at most 72
+	skewed := append(valSet.ProposerSchedule(common.Address{}, 12), monopoly[:12]...)
+	if score := valSet.FairnessScore(skewed); math.Abs(score-0.25) > 1e-9 {
+		t.Errorf("skewed score mismatch: have %v, want 0.25", score)
+	}
+	// slots of outsiders count against fairness
+	if score := valSet.FairnessScore(append(monopoly[:0:0], common.HexToAddress("0xff"))); score == 0 {
+		t.Errorf("non-member schedule scored as fair")
+	}
+
+	// a jailed validator is not expected to propose
+	valSet.Jail(addrs[0])
+	var rotation []common.Address
+	for i := 0; i < 10; i++ {
+		rotation = append(rotation, addrs[1:]...)
+	}
+	if score := valSet.FairnessScore(rotation); score != 0 {
+		t.Errorf("score with jailed validator mismatch: have %v, want 0", score)
+	}
+
+	if score := valSet.FairnessScore(nil); score != 0 {
+		t.Errorf("empty schedule score mismatch: have %v, want 0", score)
+	}
+	if score := newDefaultSet(addrs[:1], hotstuff.RoundRobin).FairnessScore(monopoly); score != 0 {
+		t.Errorf("single validator score mismatch: have %v, want 0", score)
+	}
+}
+
+// checkFairnessSequence feeds sequences of (lastProposer, round) pairs, with
+// the last proposer being either a member, a removed member or an arbitrary
+// address, and checks that the rotation never leaves the set nor starves a
+// validator.
+func checkFairnessSequence(t *testing.T, n uint8, lasts []byte, round uint64, shuffle bool) {
+	policy := hotstuff.RoundRobin
+	if shuffle {
+		policy = hotstuff.Shuffle
+	}
+	addrs := testAddresses(int(n%32) + 1)
+	valSet := newDefaultSet(addrs, policy)
+	// drop a member so that it may show up as a removed last proposer
+	if len(addrs) > 1 {
+		valSet.RemoveValidator(addrs[int(round%uint64(len(addrs)))])
+	}
+
+	for i, b := range lasts {
+		var last common.Address
+		if int(b) < len(addrs) {
+			last = addrs[b]
+		} else {
+			last = common.BytesToAddress([]byte{b, byte(i)})
+		}
+		rounds := uint64(3 * valSet.Size())
+		if err := CheckProposerFairness(valSet, last, round+uint64(i), rounds); err != nil {
+			t.Fatalf("last %x, round %d: %v", last, round+uint64(i), err)
+		}
+	}
+}
+
+func TestFairnessSequences(t *testing.T) {
+	testCases := []struct {
+		n       uint8
+		lasts   []byte
+		round   uint64
+		shuffle bool
+	}{
+		{4, []byte{0, 1, 2, 3}, 0, false},
+		{7, []byte{6, 0xff, 3}, 1 << 63, true},
+		{1, []byte{0}, math.MaxUint64, false},
+		{31, []byte{30, 0, 17, 0x80}, math.MaxUint64 - 100, true},
+	}
+	for _, test := range testCases {
+		checkFairnessSequence(t, test.n, test.lasts, test.round, test.shuffle)
+	}
+}
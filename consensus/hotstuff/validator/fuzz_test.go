@@ -0,0 +1,34 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import "testing"
+
+// FuzzProposerFairness feeds random sequences to checkFairnessSequence. Fuzzing
+// needs Go 1.18, older toolchains run the seeds in TestFairnessSequences.
+func FuzzProposerFairness(f *testing.F) {
+	f.Add(uint8(4), []byte{0, 1, 2, 3}, uint64(0), false)
+	f.Add(uint8(7), []byte{6, 0xff, 3}, uint64(1)<<63, true)
+	f.Add(uint8(1), []byte{0}, ^uint64(0), false)
+
+	f.Fuzz(checkFairnessSequence)
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+// defaultProposerHistory is the number of proposers remembered by sets which
+// don't configure it, see NewSetWithHistory.
+const defaultProposerHistory = 64
+
+// proposerHistory is a ring buffer of the proposers a set computed, a nil
+// history records nothing.
+type proposerHistory struct {
+	buf  []common.Address
+	next int  // position of the next write
+	full bool // whether buf has wrapped around
+}
+
+func newProposerHistory(size int) *proposerHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &proposerHistory{buf: make([]common.Address, size)}
+}
+
+func (h *proposerHistory) push(addr common.Address) {
+	if h == nil {
+		return
+	}
+	h.buf[h.next] = addr
+	h.next = (h.next + 1) % len(h.buf)
+	h.full = h.full || h.next == 0
+}
+
+// recent returns up to n of the latest proposers, the oldest first.
+func (h *proposerHistory) recent(n int) []common.Address {
+	if h == nil || n <= 0 {
+		return nil
+	}
+	count := h.next
+	if h.full {
+		count = len(h.buf)
+	}
+	if n > count {
+		n = count
+	}
+	out := make([]common.Address, n)
+	for i := 0; i < n; i++ {
+		out[i] = h.buf[(h.next-n+i+len(h.buf))%len(h.buf)]
+	}
+	return out
+}
+
+func (h *proposerHistory) clone() *proposerHistory {
+	if h == nil {
+		return nil
+	}
+	return &proposerHistory{buf: copyAddresses(h.buf), next: h.next, full: h.full}
+}
+
+func (h *proposerHistory) size() int {
+	if h == nil {
+		return 0
+	}
+	return len(h.buf)
+}
+
+// NewSetWithHistory creates a validator set remembering the last size
+// proposers it computed instead of the default 64, a size of zero disables
+// the history.
+func NewSetWithHistory(addrs []common.Address, policy hotstuff.SelectProposerPolicy, size int) hotstuff.ValidatorSet {
+	valSet := newDefaultSet(addrs, policy)
+	valSet.history = newProposerHistory(size)
+	return valSet
+}
+
+// RecentProposersReader is implemented by validator sets which keep the
+// history of their proposers.
+type RecentProposersReader interface {
+	RecentProposers(n int) []common.Address
+}
+
+// RecentProposers returns up to n of the latest proposers the set computed,
+// the oldest first. Operators can tell from it whether the rotation is healthy
+// or stuck on a single validator.
+func (valSet *defaultSet) RecentProposers(n int) []common.Address {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.history.recent(n)
+}
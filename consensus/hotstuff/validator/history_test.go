@@ -0,0 +1,68 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentProposers(t *testing.T) {
+	addrs := testAddresses(4)
+	recent := func(valSet hotstuff.ValidatorSet, n int) []common.Address {
+		return valSet.(RecentProposersReader).RecentProposers(n)
+	}
+	valSet := NewSetWithHistory(addrs, hotstuff.RoundRobin, 3)
+	assert.Empty(t, recent(valSet, 3))
+
+	valSet.CalcProposer(addrs[0], 0)
+	valSet.CalcProposer(addrs[1], 0)
+	assert.Equal(t, []common.Address{addrs[1], addrs[2]}, recent(valSet, 5))
+	assert.Equal(t, []common.Address{addrs[2]}, recent(valSet, 1))
+	assert.Nil(t, recent(valSet, 0))
+
+	// only the last three are kept
+	valSet.CalcProposer(addrs[2], 0)
+	valSet.CalcProposer(addrs[3], 0)
+	valSet.CalcProposer(addrs[3], 0)
+	assert.Equal(t, []common.Address{addrs[3], addrs[0], addrs[0]}, recent(valSet, 3))
+
+	// copies keep the capacity but not the content
+	cpy := valSet.Copy()
+	assert.Empty(t, recent(cpy, 3))
+	for round := uint64(0); round < 5; round++ {
+		cpy.CalcProposer(addrs[0], round)
+	}
+	assert.Equal(t, 3, len(recent(cpy, 10)))
+
+	// read only and lazy sets give access to the history as well
+	assert.Equal(t, recent(valSet, 3), recent(ReadOnly(valSet), 3))
+	lazy := NewLazySet(&mockSource{addrs: addrs, weights: []uint64{1, 1, 1, 1}}, hotstuff.RoundRobin)
+	lazy.CalcProposer(addrs[0], 0)
+	assert.Equal(t, []common.Address{addrs[1]}, recent(lazy, 3))
+
+	disabled := NewSetWithHistory(addrs, hotstuff.RoundRobin, 0)
+	disabled.CalcProposer(addrs[0], 0)
+	assert.Nil(t, recent(disabled, 1))
+
+	assert.Equal(t, defaultProposerHistory, newDefaultSet(addrs, hotstuff.RoundRobin).history.size())
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+// SetHolder holds the current validator set and lets it be swapped
+// atomically at epoch boundaries: readers never lock and always get either
+// the old or the new set, never a half-swapped one. The zero value holds no
+// set and is ready to use.
+type SetHolder struct {
+	current atomic.Value // holds a boxedSet
+}
+
+// boxedSet gives atomic.Value a single concrete type whatever the
+// implementation of the stored set, nil included.
+type boxedSet struct {
+	valSet hotstuff.ValidatorSet
+}
+
+// NewSetHolder creates a holder of valSet.
+func NewSetHolder(valSet hotstuff.ValidatorSet) *SetHolder {
+	h := new(SetHolder)
+	h.Store(valSet)
+	return h
+}
+
+// Load returns the current set, nil if none was stored.
+func (h *SetHolder) Load() hotstuff.ValidatorSet {
+	if box, ok := h.current.Load().(boxedSet); ok {
+		return box.valSet
+	}
+	return nil
+}
+
+// Store replaces the current set. The set must not be mutated afterwards by
+// the caller, readers may already hold it.
+func (h *SetHolder) Store(valSet hotstuff.ValidatorSet) {
+	h.current.Store(boxedSet{valSet})
+}
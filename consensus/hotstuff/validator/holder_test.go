@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetHolder(t *testing.T) {
+	var empty SetHolder
+	assert.Nil(t, empty.Load())
+
+	addrs := testAddresses(8)
+	valSet := newDefaultSet(addrs[:4], hotstuff.RoundRobin)
+	h := NewSetHolder(valSet)
+	assert.Equal(t, valSet, h.Load())
+
+	// sets of another implementation and nil may be stored as well
+	ro := ReadOnly(valSet)
+	h.Store(ro)
+	assert.Equal(t, ro, h.Load())
+	h.Store(nil)
+	assert.Nil(t, h.Load())
+}
+
+// TestSetHolderSwap swaps epochs while readers are running, it is meant to be
+// run with -race.
+func TestSetHolderSwap(t *testing.T) {
+	addrs := testAddresses(16)
+	epochs := []hotstuff.ValidatorSet{
+		newDefaultSet(addrs[:4], hotstuff.RoundRobin),
+		newDefaultSet(addrs[4:11], hotstuff.RoundRobin),
+		newDefaultSet(addrs[11:], hotstuff.Sticky),
+	}
+	h := NewSetHolder(epochs[0])
+
+	var (
+		wg   sync.WaitGroup
+		stop = make(chan struct{})
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// every read sees one whole epoch
+				valSet := h.Load()
+				list := valSet.AddressList()
+				if len(list) != valSet.Size() {
+					t.Errorf("size mismatch: have %d, want %d", valSet.Size(), len(list))
+					return
+				}
+				if err := valSet.CheckQuorum(list); err != nil {
+					t.Errorf("quorum failed: %v", err)
+					return
+				}
+				valSet.ProposerForRound(list[0], 1)
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		h.Store(epochs[i%len(epochs)])
+	}
+	close(stop)
+	wg.Wait()
+}
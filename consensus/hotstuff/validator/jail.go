@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import "github.com/ethereum/go-ethereum/common"
+
+// Jail excludes a validator from proposer rotation until it is unjailed, it
+// still counts toward quorum. It returns false if address is not a validator
+// or is already jailed.
+func (valSet *defaultSet) Jail(address common.Address) bool {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	if _, ok := valSet.indexes[address]; !ok {
+		return false
+	}
+	if _, ok := valSet.jailed[address]; ok {
+		return false
+	}
+	valSet.setJailed(address, true)
+	return true
+}
+
+// Unjail returns a jailed validator to proposer rotation.
+func (valSet *defaultSet) Unjail(address common.Address) bool {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	if _, ok := valSet.jailed[address]; !ok {
+		return false
+	}
+	valSet.setJailed(address, false)
+	return true
+}
+
+func (valSet *defaultSet) IsJailed(address common.Address) bool {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	_, ok := valSet.jailed[address]
+	return ok
+}
+
+// jailedMembers returns the jailed validators of valSet which are members of
+// set, so that a set derived from valSet excludes the same validators from
+// proposer rotation. It should be called with the read lock of valSet held.
+func (valSet *defaultSet) jailedMembers(set *defaultSet) map[common.Address]struct{} {
+	if len(valSet.jailed) == 0 {
+		return nil
+	}
+	jailed := make(map[common.Address]struct{}, len(valSet.jailed))
+	for addr := range valSet.jailed {
+		if _, ok := set.indexes[addr]; ok {
+			jailed[addr] = struct{}{}
+		}
+	}
+	return jailed
+}
+
+// setJailed copies the jailed map before changing it, so that detached views
+// may keep sharing the previous one. It should be called with the write lock
+// held.
+func (valSet *defaultSet) setJailed(address common.Address, jailed bool) {
+	next := make(map[common.Address]struct{}, len(valSet.jailed)+1)
+	for addr := range valSet.jailed {
+		next[addr] = struct{}{}
+	}
+	if jailed {
+		next[address] = struct{}{}
+	} else {
+		delete(next, address)
+	}
+	valSet.jailed = next
+}
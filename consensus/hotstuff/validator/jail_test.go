@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJail(t *testing.T) {
+	addrs := testAddresses(4)
+	for _, policy := range []hotstuff.SelectProposerPolicy{hotstuff.RoundRobin, hotstuff.Sticky} {
+		valSet := newDefaultSet(addrs, policy)
+
+		assert.True(t, valSet.Jail(addrs[1]))
+		assert.False(t, valSet.Jail(addrs[1]))
+		assert.False(t, valSet.Jail(common.HexToAddress("0x100")))
+		assert.True(t, valSet.IsJailed(addrs[1]))
+
+		for round := uint64(0); round < 20; round++ {
+			valSet.CalcProposer(addrs[0], round)
+			assert.NotEqual(t, addrs[1], valSet.GetProposer().Address())
+		}
+		// the jailed validator is skipped in favour of the next one
+		valSet.CalcProposer(addrs[0], 0)
+		if policy == hotstuff.RoundRobin {
+			assert.Equal(t, addrs[2], valSet.GetProposer().Address())
+		}
+
+		assert.True(t, valSet.Unjail(addrs[1]))
+		assert.False(t, valSet.Unjail(addrs[1]))
+		assert.False(t, valSet.IsJailed(addrs[1]))
+		valSet.CalcProposer(addrs[0], 0)
+		if policy == hotstuff.RoundRobin {
+			assert.Equal(t, addrs[1], valSet.GetProposer().Address())
+		}
+
+		// if everyone is jailed, the first validator proposes
+		for _, addr := range addrs {
+			valSet.Jail(addr)
+		}
+		valSet.CalcProposer(addrs[1], 5)
+		assert.Equal(t, addrs[0], valSet.GetProposer().Address())
+	}
+}
+
+func TestRemoveJailed(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	valSet.Jail(addrs[2])
+	valSet.RemoveValidator(addrs[2])
+	valSet.AddValidator(addrs[2])
+	assert.False(t, valSet.IsJailed(addrs[2]))
+}
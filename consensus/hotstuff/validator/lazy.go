@@ -0,0 +1,608 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ValidatorSource gives indexed access to a validator list kept elsewhere,
+// e.g. in the storage of a contract, so that it need not be held in memory.
+type ValidatorSource interface {
+	// Len returns the number of validators.
+	Len() int
+	// AddressAt returns the address of the validator at index i.
+	AddressAt(i int) common.Address
+	// WeightAt returns the voting power of the validator at index i.
+	WeightAt(i int) uint64
+}
+
+// lazySet is a validator set backed by a ValidatorSource. The source is
+// scanned once on first read to validate it and index the addresses, lookups
+// and quorum checks are then answered from the index, validators are read by
+// index and cached, and every other method loads the whole set once and
+// delegates to it.
+type lazySet struct {
+	src    ValidatorSource
+	policy hotstuff.SelectProposerPolicy
+
+	indexOnce sync.Once
+	indexes   map[common.Address]int // source index of each validator, see index
+	err       error                  // why the source was rejected, if it was
+
+	cacheMu sync.Mutex
+	cache   map[int]hotstuff.Validator
+
+	once   sync.Once
+	loaded int32 // set once valSet is built, see load
+	valSet *defaultSet
+}
+
+// NewLazySet creates a validator set querying src on demand. The validators
+// keep the order of the source as for NewSetUnsorted, so all nodes must read
+// the same source, which must not change while the set is in use. The first
+// read scans the addresses and weights of the source, a source listing the
+// zero address, an address twice or a validator without weight, or whose total
+// weight overflows, is rejected and the set is then empty. Lookups by index or
+// address, membership and quorum checks are answered from the source, any
+// other method, notably a membership change, loads the whole list once, after
+// which the set behaves as a regular BFT one and the source is no longer read.
+func NewLazySet(src ValidatorSource, policy hotstuff.SelectProposerPolicy) hotstuff.ValidatorSet {
+	return &lazySet{
+		src:    src,
+		policy: policy,
+		cache:  make(map[int]hotstuff.Validator),
+	}
+}
+
+// index validates the source and maps each address to its index, once, and
+// returns why the source was rejected, if it was.
+func (s *lazySet) index() error {
+	s.indexOnce.Do(func() {
+		n := s.src.Len()
+		indexes := make(map[common.Address]int, n)
+		seen := make(map[common.Address]struct{}, n)
+		total := uint64(0)
+		for i := 0; i < n && s.err == nil; i++ {
+			addr, weight := s.src.AddressAt(i), s.src.WeightAt(i)
+			if err := checkMember(seen, addr, weight); err != nil {
+				s.err = err
+				break
+			}
+			var carry uint64
+			if total, carry = bits.Add64(total, weight, 0); carry != 0 {
+				s.err = ErrWeightOverflow
+			}
+			indexes[addr] = i
+		}
+		if s.err != nil {
+			log.Error("Invalid validator source", "err", s.err)
+			indexes = make(map[common.Address]int)
+		}
+		s.indexes = indexes
+	})
+	return s.err
+}
+
+// len returns the number of validators of the source, 0 if it was rejected.
+func (s *lazySet) len() int {
+	if s.index() != nil {
+		return 0
+	}
+	return len(s.indexes)
+}
+
+// load builds the set from the source on first use, validators already read
+// by index are reused.
+func (s *lazySet) load() *defaultSet {
+	s.once.Do(func() {
+		validators := make(hotstuff.Validators, s.len())
+		for i := range validators {
+			validators[i] = s.at(i)
+		}
+		s.valSet = newUnsortedDefaultSet(validators, s.policy)
+		atomic.StoreInt32(&s.loaded, 1)
+	})
+	return s.valSet
+}
+
+// at returns the validator at index i of the source, which must be in range.
+func (s *lazySet) at(i int) hotstuff.Validator {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+
+	val, ok := s.cache[i]
+	if !ok {
+		val = NewWithWeight(s.src.AddressAt(i), s.src.WeightAt(i))
+		s.cache[i] = val
+	}
+	return val
+}
+
+// countCommitters returns the number of distinct validators among committers,
+// in strict mode the first non-member or duplicate aborts the count. The
+// source must have been indexed.
+func (s *lazySet) countCommitters(committers []common.Address, strict bool) (int, error) {
+	seen := make(map[int]struct{}, len(committers))
+	for _, addr := range committers {
+		idx, ok := s.indexes[addr]
+		if !ok {
+			if strict {
+				return len(seen), fmt.Errorf("%w: %s", ErrNonMember, addr.Hex())
+			}
+			continue
+		}
+		if _, ok := seen[idx]; ok {
+			if strict {
+				return len(seen), fmt.Errorf("%w: %s", ErrDuplicateCommitter, addr.Hex())
+			}
+			continue
+		}
+		seen[idx] = struct{}{}
+	}
+	return len(seen), nil
+}
+
+// bounds returns F and Q of the source, sets built by NewLazySet are BFT.
+func (s *lazySet) bounds() (f, q int) {
+	return faultBounds(s.len(), hotstuff.BFT)
+}
+
+func (s *lazySet) Size() int {
+	if atomic.LoadInt32(&s.loaded) == 1 {
+		return s.valSet.Size()
+	}
+	return s.len()
+}
+
+func (s *lazySet) GetByIndex(i uint64) hotstuff.Validator {
+	if atomic.LoadInt32(&s.loaded) == 1 {
+		return s.valSet.GetByIndex(i)
+	}
+	if i >= uint64(s.len()) {
+		return nil
+	}
+	return s.at(int(i))
+}
+
+func (s *lazySet) MustGetByIndex(i uint64) (hotstuff.Validator, error) {
+	if atomic.LoadInt32(&s.loaded) == 1 {
+		return s.valSet.MustGetByIndex(i)
+	}
+	if err := s.index(); err != nil {
+		return nil, err
+	}
+	if n := s.len(); i >= uint64(n) {
+		return nil, fmt.Errorf("%w: index %d out of range, size %d", ErrInvalidParticipant, i, n)
+	}
+	return s.at(int(i)), nil
+}
+
+func (s *lazySet) GetByAddress(addr common.Address) (int, hotstuff.Validator) {
+	if atomic.LoadInt32(&s.loaded) == 1 {
+		return s.valSet.GetByAddress(addr)
+	}
+	s.index()
+	if i, ok := s.indexes[addr]; ok {
+		return i, s.at(i)
+	}
+	return -1, nil
+}
+
+func (s *lazySet) Contains(addr common.Address) bool {
+	if atomic.LoadInt32(&s.loaded) == 1 {
+		return s.valSet.Contains(addr)
+	}
+	s.index()
+	_, ok := s.indexes[addr]
+	return ok
+}
+
+func (s *lazySet) ParticipantsNumber(list []common.Address) int {
+	if atomic.LoadInt32(&s.loaded) == 1 {
+		return s.valSet.ParticipantsNumber(list)
+	}
+	s.index()
+	size := 0
+	for _, addr := range list {
+		if _, ok := s.indexes[addr]; ok {
+			size++
+		}
+	}
+	return size
+}
+
+func (s *lazySet) CheckQuorum(committers []common.Address) error {
+	if atomic.LoadInt32(&s.loaded) == 1 {
+		return s.valSet.CheckQuorum(committers)
+	}
+	if err := s.index(); err != nil {
+		return err
+	}
+	if len(s.indexes) == 0 {
+		return ErrEmptySet
+	}
+	count, _ := s.countCommitters(committers, false)
+	if _, q := s.bounds(); count < q {
+		return ErrBelowQuorum
+	}
+	return nil
+}
+
+func (s *lazySet) CheckQuorumStrict(committers []common.Address) error {
+	if atomic.LoadInt32(&s.loaded) == 1 {
+		return s.valSet.CheckQuorumStrict(committers)
+	}
+	if err := s.index(); err != nil {
+		return err
+	}
+	n := len(s.indexes)
+	if n == 0 {
+		return ErrEmptySet
+	}
+	if len(committers) > n {
+		return fmt.Errorf("%w: have %d, want at most %d", ErrTooManyCommitters, len(committers), n)
+	}
+	count, err := s.countCommitters(committers, true)
+	if err != nil {
+		return err
+	}
+	if _, q := s.bounds(); count < q {
+		return ErrBelowQuorum
+	}
+	return nil
+}
+
+func (s *lazySet) F() int {
+	if atomic.LoadInt32(&s.loaded) == 1 {
+		return s.valSet.F()
+	}
+	f, _ := s.bounds()
+	return f
+}
+
+func (s *lazySet) Q() int {
+	if atomic.LoadInt32(&s.loaded) == 1 {
+		return s.valSet.Q()
+	}
+	_, q := s.bounds()
+	return q
+}
+
+func (s *lazySet) QuorumSize() int {
+	return s.Q()
+}
+
+func (s *lazySet) QuorumReached(count int) bool {
+	return count >= s.QuorumSize()
+}
+
+func (s *lazySet) Policy() hotstuff.SelectProposerPolicy { return s.policy }
+
+func (s *lazySet) CalcProposer(lastProposer common.Address, round uint64) {
+	s.load().CalcProposer(lastProposer, round)
+}
+
+func (s *lazySet) CalcProposerCtx(ctx context.Context, lastProposer common.Address, round uint64) error {
+	return s.load().CalcProposerCtx(ctx, lastProposer, round)
+}
+
+func (s *lazySet) ProposerForRound(lastProposer common.Address, round uint64) hotstuff.Validator {
+	return s.load().ProposerForRound(lastProposer, round)
+}
+
+func (s *lazySet) NextProposer() hotstuff.Validator {
+	return s.load().NextProposer()
+}
+
+func (s *lazySet) ProposerSchedule(lastProposer common.Address, rounds uint64) []common.Address {
+	return s.load().ProposerSchedule(lastProposer, rounds)
+}
+
+func (s *lazySet) Seed(lastProposer common.Address, round uint64) uint64 {
+	return s.load().Seed(lastProposer, round)
+}
+
+func (s *lazySet) CalcProposerFromSeed(seed common.Hash, round uint64) {
+	s.load().CalcProposerFromSeed(seed, round)
+}
+
+func (s *lazySet) ProposerSeed() common.Hash {
+	return s.load().ProposerSeed()
+}
+
+func (s *lazySet) SetEpochSeed(seed common.Hash) {
+	s.load().SetEpochSeed(seed)
+}
+
+func (s *lazySet) EpochSeed() common.Hash {
+	return s.load().EpochSeed()
+}
+
+func (s *lazySet) CalcProposerGlobal(lastProposer common.Address, epoch, round, roundsPerEpoch uint64) {
+	s.load().CalcProposerGlobal(lastProposer, epoch, round, roundsPerEpoch)
+}
+
+func (s *lazySet) CalcProposerByRound(round uint64) {
+	s.load().CalcProposerByRound(round)
+}
+
+func (s *lazySet) SetLastProposer(addr common.Address) {
+	s.load().SetLastProposer(addr)
+}
+
+func (s *lazySet) LastProposer() common.Address {
+	return s.load().LastProposer()
+}
+
+func (s *lazySet) SetGenesisProposer(addr common.Address) {
+	s.load().SetGenesisProposer(addr)
+}
+
+func (s *lazySet) GenesisProposer() common.Address {
+	return s.load().GenesisProposer()
+}
+
+func (s *lazySet) SetNoRepeatProposer(noRepeat bool) {
+	s.load().SetNoRepeatProposer(noRepeat)
+}
+
+func (s *lazySet) NoRepeatProposer() bool {
+	return s.load().NoRepeatProposer()
+}
+
+func (s *lazySet) AdvanceProposer() {
+	s.load().AdvanceProposer()
+}
+
+func (s *lazySet) CalcProposerByIndex(index uint64) {
+	s.load().CalcProposerByIndex(index)
+}
+
+func (s *lazySet) List() []hotstuff.Validator {
+	return s.load().List()
+}
+
+func (s *lazySet) AddressList() []common.Address {
+	return s.load().AddressList()
+}
+
+func (s *lazySet) BLSPublicKeys() [][]byte {
+	return s.load().BLSPublicKeys()
+}
+
+func (s *lazySet) AsMap() map[common.Address]hotstuff.Validator {
+	return s.load().AsMap()
+}
+
+func (s *lazySet) VerifyProposer(addr common.Address, round uint64, proof []byte) error {
+	return s.load().VerifyProposer(addr, round, proof)
+}
+
+func (s *lazySet) GetProposer() hotstuff.Validator {
+	return s.load().GetProposer()
+}
+
+func (s *lazySet) IsProposer(address common.Address) bool {
+	return s.load().IsProposer(address)
+}
+
+func (s *lazySet) IsProposerForRound(address common.Address, lastProposer common.Address, round uint64) bool {
+	return s.load().IsProposerForRound(address, lastProposer, round)
+}
+
+func (s *lazySet) IsProposerIndex(i uint64) bool {
+	return s.load().IsProposerIndex(i)
+}
+
+func (s *lazySet) ProposerIndex() int {
+	return s.load().ProposerIndex()
+}
+
+func (s *lazySet) AddValidator(address common.Address) bool {
+	return s.load().AddValidator(address)
+}
+
+func (s *lazySet) RemoveValidator(address common.Address) bool {
+	return s.load().RemoveValidator(address)
+}
+
+func (s *lazySet) RemoveValidatorGet(address common.Address) (hotstuff.Validator, bool) {
+	return s.load().RemoveValidatorGet(address)
+}
+
+func (s *lazySet) RemoveValidatorByIndex(i uint64) bool {
+	return s.load().RemoveValidatorByIndex(i)
+}
+
+func (s *lazySet) AddValidators(addrs []common.Address) int {
+	return s.load().AddValidators(addrs)
+}
+
+func (s *lazySet) RemoveValidators(addrs []common.Address) int {
+	return s.load().RemoveValidators(addrs)
+}
+
+func (s *lazySet) Jail(address common.Address) bool {
+	return s.load().Jail(address)
+}
+
+func (s *lazySet) Unjail(address common.Address) bool {
+	return s.load().Unjail(address)
+}
+
+func (s *lazySet) IsJailed(address common.Address) bool {
+	return s.load().IsJailed(address)
+}
+
+func (s *lazySet) SetProposerEligible(address common.Address, eligible bool) bool {
+	return s.load().SetProposerEligible(address, eligible)
+}
+
+func (s *lazySet) Copy() hotstuff.ValidatorSet {
+	return s.load().Copy()
+}
+
+func (s *lazySet) DeepCopy() hotstuff.ValidatorSet {
+	return s.load().DeepCopy()
+}
+
+func (s *lazySet) Union(other hotstuff.ValidatorSet) hotstuff.ValidatorSet {
+	return s.load().Union(other)
+}
+
+func (s *lazySet) Intersect(other hotstuff.ValidatorSet) hotstuff.ValidatorSet {
+	return s.load().Intersect(other)
+}
+
+func (s *lazySet) Subset(addrs []common.Address) (hotstuff.ValidatorSet, error) {
+	return s.load().Subset(addrs)
+}
+
+func (s *lazySet) FilterMembers(list []common.Address) (members, nonMembers []common.Address) {
+	return s.load().FilterMembers(list)
+}
+
+func (s *lazySet) AbsentMembers(committers []common.Address) []common.Address {
+	return s.load().AbsentMembers(committers)
+}
+
+func (s *lazySet) CheckWeightedQuorum(committers []common.Address) error {
+	return s.load().CheckWeightedQuorum(committers)
+}
+
+func (s *lazySet) CheckBitmapQuorum(bitmap []byte) error {
+	return s.load().CheckBitmapQuorum(bitmap)
+}
+
+func (s *lazySet) BitmapToAddresses(bitmap []byte) ([]common.Address, error) {
+	return s.load().BitmapToAddresses(bitmap)
+}
+
+func (s *lazySet) AddressesToBitmap(addrs []common.Address) ([]byte, error) {
+	return s.load().AddressesToBitmap(addrs)
+}
+
+func (s *lazySet) MinQuorumCommitters() []common.Address {
+	return s.load().MinQuorumCommitters()
+}
+
+func (s *lazySet) CanReachQuorum() bool {
+	return s.load().CanReachQuorum()
+}
+
+func (s *lazySet) HealthCheck() error {
+	return s.load().HealthCheck()
+}
+
+func (s *lazySet) Params() hotstuff.ValidatorSetParams {
+	return s.load().Params()
+}
+
+func (s *lazySet) TotalWeight() uint64 {
+	return s.load().TotalWeight()
+}
+
+func (s *lazySet) ValidateTotalWeight(expected uint64) error {
+	return s.load().ValidateTotalWeight(expected)
+}
+
+func (s *lazySet) WeightedF() uint64 {
+	return s.load().WeightedF()
+}
+
+func (s *lazySet) WeightedQ() uint64 {
+	return s.load().WeightedQ()
+}
+
+func (s *lazySet) FaultModel() hotstuff.FaultModel {
+	return s.load().FaultModel()
+}
+
+func (s *lazySet) Cmp(src hotstuff.ValidatorSet) bool {
+	return s.load().Cmp(src)
+}
+
+func (s *lazySet) EqualUnordered(addrs []common.Address) bool {
+	return s.load().EqualUnordered(addrs)
+}
+
+func (s *lazySet) Equal(src hotstuff.ValidatorSet) bool {
+	return s.load().Equal(src)
+}
+
+func (s *lazySet) Hash() common.Hash {
+	return s.load().Hash()
+}
+
+func (s *lazySet) Epoch() uint64 {
+	return s.load().Epoch()
+}
+
+func (s *lazySet) Transition(newAddrs []common.Address, epoch uint64) hotstuff.ValidatorSet {
+	return s.load().Transition(newAddrs, epoch)
+}
+
+func (s *lazySet) EpochChanges() (added, removed []common.Address) {
+	return s.load().EpochChanges()
+}
+
+func (s *lazySet) RotateBy(offset uint64) {
+	s.load().RotateBy(offset)
+}
+
+func (s *lazySet) SetMetrics(registry MetricsRegistry) {
+	s.load().SetMetrics(registry)
+}
+
+func (s *lazySet) Compare(src hotstuff.ValidatorSet) (bool, int, string) {
+	return s.load().Compare(src)
+}
+
+func (s *lazySet) FairnessScore(schedule []common.Address) float64 {
+	return s.load().FairnessScore(schedule)
+}
+
+func (s *lazySet) Key() [32]byte {
+	return s.load().Key()
+}
+
+func (s *lazySet) ConfigFingerprint() common.Hash {
+	return s.load().ConfigFingerprint()
+}
+
+func (s *lazySet) RecentProposers(n int) []common.Address {
+	return s.load().RecentProposers(n)
+}
+
+func (s *lazySet) Snapshot() ValidatorSnapshot {
+	return s.load().Snapshot()
+}
+
+func (s *lazySet) SubscribeMembershipChange() (<-chan MembershipEvent, func()) {
+	return s.load().SubscribeMembershipChange()
+}
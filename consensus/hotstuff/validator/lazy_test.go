@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockSource serves validators from memory and counts the reads.
+type mockSource struct {
+	addrs   []common.Address
+	weights []uint64
+	reads   int
+}
+
+func (m *mockSource) Len() int { return len(m.addrs) }
+
+func (m *mockSource) AddressAt(i int) common.Address {
+	m.reads++
+	return m.addrs[i]
+}
+
+func (m *mockSource) WeightAt(i int) uint64 { return m.weights[i] }
+
+func TestLazySet(t *testing.T) {
+	addrs := testAddresses(5)
+	// the source order is kept rather than sorted
+	order := []common.Address{addrs[3], addrs[0], addrs[4], addrs[1], addrs[2]}
+	src := &mockSource{addrs: order, weights: []uint64{1, 2, 3, 4, 5}}
+	valSet := NewLazySet(src, hotstuff.RoundRobin)
+
+	// the first read scans the source once, index lookups then only read
+	// the requested validators, once
+	assert.Equal(t, 5, valSet.Size())
+	assert.Equal(t, 5, src.reads)
+	assert.Equal(t, addrs[4], valSet.GetByIndex(2).Address())
+	assert.Equal(t, uint64(3), valSet.GetByIndex(2).Weight())
+	val, err := valSet.MustGetByIndex(4)
+	assert.NoError(t, err)
+	assert.Equal(t, addrs[2], val.Address())
+	assert.Equal(t, 7, src.reads)
+	assert.Nil(t, valSet.GetByIndex(5))
+	_, err = valSet.MustGetByIndex(5)
+	assert.ErrorIs(t, err, ErrInvalidParticipant)
+
+	// lookups and quorum checks are served from the source
+	idx, val := valSet.GetByAddress(addrs[4])
+	assert.Equal(t, 2, idx)
+	assert.Equal(t, addrs[4], val.Address())
+	idx, val = valSet.GetByAddress(common.HexToAddress("0xdead"))
+	assert.Equal(t, -1, idx)
+	assert.Nil(t, val)
+	assert.True(t, valSet.Contains(addrs[1]))
+	assert.False(t, valSet.Contains(common.HexToAddress("0xdead")))
+	assert.Equal(t, 2, valSet.ParticipantsNumber([]common.Address{addrs[1], addrs[1], common.HexToAddress("0xdead")}))
+	assert.Equal(t, 1, valSet.F())
+	assert.Equal(t, 4, valSet.Q())
+	assert.True(t, valSet.QuorumReached(4))
+	assert.NoError(t, valSet.CheckQuorum(order[:4]))
+	assert.Equal(t, ErrBelowQuorum, valSet.CheckQuorum(append(order[:3:3], order[0])))
+	assert.NoError(t, valSet.CheckQuorumStrict(order[:4]))
+	assert.ErrorIs(t, valSet.CheckQuorumStrict(append(order[:3:3], order[0])), ErrDuplicateCommitter)
+	assert.Equal(t, 7, src.reads)
+	assert.Equal(t, int32(0), valSet.(*lazySet).loaded)
+
+	// anything else loads the remaining validators once
+	assert.Equal(t, order, valSet.AddressList())
+	assert.Equal(t, 10, src.reads)
+	assert.Equal(t, uint64(15), valSet.TotalWeight())
+	assert.NoError(t, valSet.CheckQuorum(order[:4]))
+	assert.Equal(t, 10, src.reads)
+
+	// selection follows the source order like an unsorted set
+	regular := NewSetUnsorted(order, hotstuff.RoundRobin)
+	for round := uint64(0); round < 10; round++ {
+		assert.Equal(t, regular.ProposerForRound(order[1], round).Address(), valSet.ProposerForRound(order[1], round).Address(), "round %d", round)
+	}
+	valSet.CalcProposer(order[1], 0)
+	assert.Equal(t, order[2], valSet.GetProposer().Address())
+
+	// once loaded, changes are served by the set rather than the source
+	assert.True(t, valSet.RemoveValidator(order[0]))
+	assert.Equal(t, 4, valSet.Size())
+	assert.Equal(t, order[1], valSet.GetByIndex(0).Address())
+	assert.False(t, valSet.Contains(order[0]))
+	assert.Equal(t, 10, src.reads)
+}
+
+func TestLazySetEmpty(t *testing.T) {
+	valSet := NewLazySet(&mockSource{}, hotstuff.Sticky)
+	assert.Equal(t, 0, valSet.Size())
+	assert.Equal(t, hotstuff.Sticky, valSet.Policy())
+	assert.Nil(t, valSet.GetByIndex(0))
+	assert.Equal(t, ErrEmptySet, valSet.CheckQuorum(nil))
+}
+
+func TestLazySetInvalidSource(t *testing.T) {
+	addrs := testAddresses(3)
+	testCases := []struct {
+		src *mockSource
+		err error
+	}{
+		{&mockSource{addrs: []common.Address{addrs[0], {}}, weights: []uint64{1, 1}}, ErrZeroAddress},
+		{&mockSource{addrs: []common.Address{addrs[0], addrs[1], addrs[0]}, weights: []uint64{1, 1, 1}}, ErrInvalidParticipant},
+		{&mockSource{addrs: addrs[:2], weights: []uint64{1, 0}}, ErrInvalidParticipant},
+		{&mockSource{addrs: addrs[:2], weights: []uint64{1 << 63, 1 << 63}}, ErrWeightOverflow},
+	}
+	for i, test := range testCases {
+		// a rejected source leaves the set empty, before and after loading
+		valSet := NewLazySet(test.src, hotstuff.RoundRobin)
+		assert.Equal(t, 0, valSet.Size(), "test %d", i)
+		assert.Nil(t, valSet.GetByIndex(0), "test %d", i)
+		assert.False(t, valSet.Contains(addrs[0]), "test %d", i)
+		assert.ErrorIs(t, valSet.CheckQuorum(addrs[:1]), test.err, "test %d", i)
+		_, err := valSet.MustGetByIndex(0)
+		assert.ErrorIs(t, err, test.err, "test %d", i)
+		assert.Empty(t, valSet.AddressList(), "test %d", i)
+		assert.Equal(t, 0, valSet.Size(), "test %d", i)
+	}
+}
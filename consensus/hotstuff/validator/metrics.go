@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+const (
+	sizeGaugeName              = "validator_set_size"
+	faultyToleranceGaugeName   = "validator_set_faulty_tolerance"
+	quorumGaugeName            = "validator_set_quorum"
+	proposerChangesCounterName = "validator_proposer_changes_total"
+)
+
+// Gauge is the subset of a metrics gauge the validator set reports to, it is
+// satisfied by metrics.Gauge.
+type Gauge interface {
+	Update(int64)
+}
+
+// Counter is the subset of a metrics counter the validator set reports to, it
+// is satisfied by metrics.Counter.
+type Counter interface {
+	Inc(int64)
+}
+
+// MetricsRegistry creates or looks up the named metrics. It decouples the
+// validator set from any particular metrics library.
+type MetricsRegistry interface {
+	Gauge(name string) Gauge
+	Counter(name string) Counter
+}
+
+// MetricsReporter is implemented by validator sets which report to a
+// MetricsRegistry.
+type MetricsReporter interface {
+	SetMetrics(registry MetricsRegistry)
+}
+
+type setMetrics struct {
+	size            Gauge
+	faultyTolerance Gauge
+	quorum          Gauge
+	proposerChanges Counter
+}
+
+// update publishes the membership gauges, it is a no-op if no registry was
+// supplied.
+func (m *setMetrics) update(size, f, q int) {
+	if m == nil {
+		return
+	}
+	m.size.Update(int64(size))
+	m.faultyTolerance.Update(int64(f))
+	m.quorum.Update(int64(q))
+}
+
+// SetMetrics reports the set size, the faulty tolerance, Q and the proposer
+// changes to the given registry, a nil registry disables reporting. Copies
+// of the set do not inherit the registry.
+func (valSet *defaultSet) SetMetrics(registry MetricsRegistry) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	if registry == nil {
+		valSet.metrics = nil
+		return
+	}
+	valSet.metrics = &setMetrics{
+		size:            registry.Gauge(sizeGaugeName),
+		faultyTolerance: registry.Gauge(faultyToleranceGaugeName),
+		quorum:          registry.Gauge(quorumGaugeName),
+		proposerChanges: registry.Counter(proposerChangesCounterName),
+	}
+	valSet.metrics.update(len(valSet.validators), valSet.f, valSet.q)
+}
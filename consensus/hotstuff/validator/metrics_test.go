@@ -0,0 +1,88 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRegistry struct {
+	gauges   map[string]*metrics.StandardGauge
+	counters map[string]*metrics.StandardCounter
+}
+
+func newTestRegistry() *testRegistry {
+	return &testRegistry{
+		gauges:   make(map[string]*metrics.StandardGauge),
+		counters: make(map[string]*metrics.StandardCounter),
+	}
+}
+
+func (r *testRegistry) Gauge(name string) Gauge {
+	g := new(metrics.StandardGauge)
+	r.gauges[name] = g
+	return g
+}
+
+func (r *testRegistry) Counter(name string) Counter {
+	c := new(metrics.StandardCounter)
+	r.counters[name] = c
+	return c
+}
+
+func TestMetrics(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(4), hotstuff.RoundRobin)
+
+	// nothing is reported without registry
+	valSet.AddValidator(common.HexToAddress("0x100"))
+	valSet.CalcProposer(common.Address{}, 1)
+
+	registry := newTestRegistry()
+	valSet.SetMetrics(registry)
+	size := registry.gauges[sizeGaugeName]
+	faulty := registry.gauges[faultyToleranceGaugeName]
+	quorum := registry.gauges[quorumGaugeName]
+	changes := registry.counters[proposerChangesCounterName]
+	assert.Equal(t, int64(5), size.Value())
+	assert.Equal(t, int64(1), faulty.Value())
+	assert.Equal(t, int64(4), quorum.Value())
+
+	valSet.AddValidator(common.HexToAddress("0x101"))
+	valSet.AddValidator(common.HexToAddress("0x102"))
+	assert.Equal(t, int64(7), size.Value())
+	assert.Equal(t, int64(2), faulty.Value())
+	assert.Equal(t, int64(5), quorum.Value())
+	valSet.RemoveValidator(common.HexToAddress("0x102"))
+	assert.Equal(t, int64(6), size.Value())
+	assert.Equal(t, int64(4), quorum.Value())
+
+	valSet.CalcProposer(common.Address{}, 2)
+	valSet.CalcProposer(common.Address{}, 2)
+	valSet.CalcProposer(common.Address{}, 3)
+	assert.Equal(t, int64(2), changes.Count())
+
+	valSet.SetMetrics(nil)
+	valSet.AddValidator(common.HexToAddress("0x102"))
+	assert.Equal(t, int64(6), size.Value())
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+// ErrReadOnly is returned when a read only validator set is asked to change.
+var ErrReadOnly = errors.New("read only validator set")
+
+// readOnlySet delegates every query to the wrapped set and ignores every
+// mutation, including the proposer calculation.
+type readOnlySet struct {
+	hotstuff.ValidatorSet
+}
+
+// ReadOnly wraps vs so that it can be handed to code which must not modify it,
+// e.g. the historical sets cached per height. Mutating methods are no-ops
+// which report that nothing changed, Copy and DeepCopy return mutable copies.
+func ReadOnly(vs hotstuff.ValidatorSet) hotstuff.ValidatorSet {
+	if vs == nil {
+		return nil
+	}
+	if _, ok := vs.(*readOnlySet); ok {
+		return vs
+	}
+	return &readOnlySet{vs}
+}
+
+func (ro *readOnlySet) CalcProposer(common.Address, uint64) {}
+
+func (ro *readOnlySet) CalcProposerCtx(context.Context, common.Address, uint64) error {
+	return ErrReadOnly
+}
+
+func (ro *readOnlySet) CalcProposerFromSeed(common.Hash, uint64) {}
+
+func (ro *readOnlySet) CalcProposerByRound(uint64) {}
+
+func (ro *readOnlySet) CalcProposerGlobal(common.Address, uint64, uint64, uint64) {}
+
+func (ro *readOnlySet) SetLastProposer(common.Address) {}
+
+func (ro *readOnlySet) SetGenesisProposer(common.Address) {}
+
+func (ro *readOnlySet) SetNoRepeatProposer(bool) {}
+
+func (ro *readOnlySet) CalcProposerByIndex(uint64) {}
+
+func (ro *readOnlySet) AdvanceProposer() {}
+
+func (ro *readOnlySet) SetEpochSeed(common.Hash) {}
+
+func (ro *readOnlySet) AddValidator(common.Address) bool { return false }
+
+func (ro *readOnlySet) RemoveValidator(common.Address) bool { return false }
+
+func (ro *readOnlySet) RemoveValidatorGet(common.Address) (hotstuff.Validator, bool) {
+	return nil, false
+}
+
+func (ro *readOnlySet) RemoveValidatorByIndex(uint64) bool { return false }
+
+func (ro *readOnlySet) AddValidators([]common.Address) int { return 0 }
+
+func (ro *readOnlySet) RemoveValidators([]common.Address) int { return 0 }
+
+func (ro *readOnlySet) Jail(common.Address) bool { return false }
+
+func (ro *readOnlySet) Unjail(common.Address) bool { return false }
+
+func (ro *readOnlySet) SetProposerEligible(common.Address, bool) bool { return false }
+
+func (ro *readOnlySet) RotateBy(uint64) {}
+
+func (ro *readOnlySet) SetMetrics(MetricsRegistry) {}
+
+func (ro *readOnlySet) Compare(src hotstuff.ValidatorSet) (bool, int, string) {
+	return compareSets(ro, src)
+}
+
+func (ro *readOnlySet) FairnessScore(schedule []common.Address) float64 {
+	return fairnessScore(ro, schedule)
+}
+
+func (ro *readOnlySet) Key() [32]byte {
+	return ro.Hash()
+}
+
+// The remaining helpers are answered by the wrapped set if it provides them.
+
+func (ro *readOnlySet) ConfigFingerprint() common.Hash {
+	if reader, ok := ro.ValidatorSet.(ConfigFingerprintReader); ok {
+		return reader.ConfigFingerprint()
+	}
+	return common.Hash{}
+}
+
+func (ro *readOnlySet) RecentProposers(n int) []common.Address {
+	if reader, ok := ro.ValidatorSet.(RecentProposersReader); ok {
+		return reader.RecentProposers(n)
+	}
+	return nil
+}
+
+func (ro *readOnlySet) Snapshot() ValidatorSnapshot {
+	if snapshotter, ok := ro.ValidatorSet.(Snapshotter); ok {
+		return snapshotter.Snapshot()
+	}
+	return ValidatorSnapshot{}
+}
+
+// SubscribeMembershipChange subscribes to the wrapped set, which may still be
+// changed by its owner. If it posts no events, the channel is closed at once.
+func (ro *readOnlySet) SubscribeMembershipChange() (<-chan MembershipEvent, func()) {
+	if notifier, ok := ro.ValidatorSet.(MembershipNotifier); ok {
+		return notifier.SubscribeMembershipChange()
+	}
+	ch := make(chan MembershipEvent)
+	close(ch)
+	return ch, func() {}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnly(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs[:3], hotstuff.RoundRobin)
+	ro := ReadOnly(valSet)
+	assert.Equal(t, ro, ReadOnly(ro))
+	assert.Nil(t, ReadOnly(nil))
+
+	proposer := ro.GetProposer()
+	assert.False(t, ro.AddValidator(addrs[3]))
+	assert.False(t, ro.RemoveValidator(addrs[0]))
+	removed, ok := ro.RemoveValidatorGet(addrs[0])
+	assert.False(t, ok)
+	assert.Nil(t, removed)
+	assert.Equal(t, 0, ro.AddValidators(addrs[3:]))
+	assert.Equal(t, 0, ro.RemoveValidators(addrs[:1]))
+	assert.False(t, ro.Jail(addrs[1]))
+	assert.False(t, ro.Unjail(addrs[1]))
+	ro.CalcProposer(addrs[0], 1)
+	ro.CalcProposerByIndex(3)
+	assert.Equal(t, ErrReadOnly, ro.CalcProposerCtx(context.Background(), addrs[0], 1))
+
+	assert.Equal(t, 3, valSet.Size())
+	assert.False(t, valSet.IsJailed(addrs[1]))
+	assert.Equal(t, proposer, valSet.GetProposer())
+
+	// reads go through to the wrapped set
+	assert.Equal(t, valSet.AddressList(), ro.AddressList())
+	assert.Equal(t, valSet.ProposerForRound(addrs[0], 1), ro.ProposerForRound(addrs[0], 1))
+	assert.True(t, ro.Equal(valSet))
+	assert.NoError(t, ro.CheckQuorum(addrs[:3]))
+
+	// while copies may be modified
+	cpy := ro.Copy()
+	assert.True(t, cpy.AddValidator(addrs[3]))
+	assert.Equal(t, 3, ro.Size())
+}
+
+func TestReadOnlyHelpers(t *testing.T) {
+	addrs := testAddresses(4)
+	valSet := newDefaultSet(addrs[:3], hotstuff.RoundRobin)
+	valSet.CalcProposer(addrs[0], 0)
+	ro := ReadOnly(valSet)
+
+	// the helpers of the wrapped set are reachable through the interfaces
+	ro.(Rotator).RotateBy(1)
+	ro.(MetricsReporter).SetMetrics(newTestRegistry())
+	assert.Equal(t, addrs[:3], valSet.AddressList())
+	assert.Nil(t, valSet.metrics)
+
+	equal, _, _ := ro.(Comparer).Compare(valSet)
+	assert.True(t, equal)
+	assert.Equal(t, valSet.FairnessScore(addrs[:2]), ro.(FairnessScorer).FairnessScore(addrs[:2]))
+	assert.Equal(t, valSet.Key(), ro.(KeyReader).Key())
+	assert.Equal(t, valSet.ConfigFingerprint(), ro.(ConfigFingerprintReader).ConfigFingerprint())
+	assert.Equal(t, valSet.RecentProposers(5), ro.(RecentProposersReader).RecentProposers(5))
+	assert.Equal(t, valSet.Snapshot().AddressList(), ro.(Snapshotter).Snapshot().AddressList())
+
+	events, unsubscribe := ro.(MembershipNotifier).SubscribeMembershipChange()
+	defer unsubscribe()
+	valSet.AddValidator(addrs[3])
+	assert.Equal(t, addrs[3], (<-events).Address)
+}
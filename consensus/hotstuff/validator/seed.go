@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+// ProposerSeedReader is implemented by validator sets which carry the seed of
+// the hash seeded policy.
+type ProposerSeedReader interface {
+	ProposerSeed() common.Hash
+}
+
+// CalcProposerFromSeed stores seed, typically the hash of the last block, and
+// calculates the proposer of round from it regardless of the policy. Unlike
+// the index based rotation, the last proposer can not pick its successor by
+// deciding whether to produce a block, while all honest nodes seeing the same
+// hash still agree on the proposer.
+func (valSet *defaultSet) CalcProposerFromSeed(seed common.Hash, round uint64) {
+	valSet.validatorMu.Lock()
+	valSet.seed = seed
+	view := valSet.detach()
+	valSet.validatorMu.Unlock()
+
+	view.selector = hashSeededSelector
+	proposer := view.selectProposer(common.Address{}, round)
+
+	valSet.validatorMu.Lock()
+	valSet.storeSelected(view, proposer)
+	valSet.validatorMu.Unlock()
+}
+
+// ProposerSeed returns the seed last given to CalcProposerFromSeed.
+func (valSet *defaultSet) ProposerSeed() common.Hash {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.seed
+}
+
+// hashSeededSelector starts the rotation at the index drawn from the seed and
+// advances it by round, the last proposer is ignored.
+func hashSeededSelector(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+	size := uint64(valSet.Size())
+	if size == 0 {
+		return nil
+	}
+	var seed common.Hash
+	if reader, ok := valSet.(ProposerSeedReader); ok {
+		seed = reader.ProposerSeed()
+	}
+	offset := new(big.Int).Mod(seed.Big(), new(big.Int).SetUint64(size)).Uint64()
+	return nextActive(valSet, (offset+round%size)%size)
+}
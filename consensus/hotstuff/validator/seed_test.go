@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalcProposerFromSeed(t *testing.T) {
+	addrs := testAddresses(5)
+	seed := crypto.Keccak256Hash([]byte("block"))
+
+	valSet := newDefaultSet(addrs, hotstuff.HashSeeded)
+	other := newDefaultSet(addrs, hotstuff.RoundRobin)
+	for _, round := range []uint64{0, 1, 2, 3, 4, 5, math.MaxUint64} {
+		valSet.CalcProposerFromSeed(seed, round)
+		other.CalcProposerFromSeed(seed, round)
+		// honest nodes agree whatever their policy
+		assert.Equal(t, valSet.GetProposer(), other.GetProposer())
+	}
+	assert.Equal(t, seed, valSet.ProposerSeed())
+
+	// the proposer advances with the round
+	valSet.CalcProposerFromSeed(seed, 0)
+	first, _ := valSet.GetByAddress(valSet.GetProposer().Address())
+	valSet.CalcProposerFromSeed(seed, 1)
+	second, _ := valSet.GetByAddress(valSet.GetProposer().Address())
+	assert.Equal(t, (first+1)%len(addrs), second)
+
+	// and the last proposer has no say
+	for _, last := range addrs {
+		valSet.CalcProposer(last, 1)
+		assert.Equal(t, addrs[second], valSet.GetProposer().Address())
+	}
+
+	// the seed is carried over by copies
+	cpy := valSet.Copy()
+	assert.Equal(t, seed, cpy.ProposerSeed())
+	cpy.CalcProposer(common.Address{}, 1)
+	assert.Equal(t, addrs[second], cpy.GetProposer().Address())
+
+	// different hashes spread the proposer over the set
+	picked := make(map[common.Address]struct{})
+	for i := 0; i < 100; i++ {
+		valSet.CalcProposerFromSeed(crypto.Keccak256Hash([]byte{byte(i)}), 0)
+		picked[valSet.GetProposer().Address()] = struct{}{}
+	}
+	assert.Equal(t, len(addrs), len(picked))
+
+	empty := newDefaultSet(nil, hotstuff.HashSeeded)
+	empty.CalcProposerFromSeed(seed, 0)
+	assert.Nil(t, empty.GetProposer())
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EpochSeedReader is implemented by validator sets which carry the seed of the
+// shuffle policy.
+type EpochSeedReader interface {
+	EpochSeed() common.Hash
+}
+
+// SetEpochSeed sets the seed the shuffle policy derives its proposer order
+// from. It should be set once per epoch, it is carried over by Copy but not by
+// Transition, as the next epoch needs a seed of its own.
+func (valSet *defaultSet) SetEpochSeed(seed common.Hash) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+	valSet.epochSeed = seed
+}
+
+// EpochSeed returns the seed of the shuffle policy.
+func (valSet *defaultSet) EpochSeed() common.Hash {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return valSet.epochSeed
+}
+
+// shuffle returns a Fisher-Yates permutation of [0, n) which only depends on
+// seed, the random source being the keccak256 hash of the seed and the step.
+func shuffle(seed common.Hash, n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	var enc [8]byte
+	for i := n - 1; i > 0; i-- {
+		binary.BigEndian.PutUint64(enc[:], uint64(i))
+		h := crypto.Keccak256(seed.Bytes(), enc[:])
+		j := int(binary.BigEndian.Uint64(h[:8]) % uint64(i+1))
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+	return perm
+}
+
+// shuffleSelector rotates over the validators in the order of the permutation
+// drawn from the epoch seed, starting right after the last proposer like the
+// round robin policy. Jailed validators are skipped.
+func shuffleSelector(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+	size := valSet.Size()
+	if size == 0 {
+		return nil
+	}
+	var seed common.Hash
+	if reader, ok := valSet.(EpochSeedReader); ok {
+		seed = reader.EpochSeed()
+	}
+	perm := shuffle(seed, size)
+
+	n := uint64(size)
+	start := round % n
+	if idx, val := valSet.GetByAddress(proposer); val != nil {
+		for pos, i := range perm {
+			if i == idx {
+				start = (uint64(pos) + 1 + round%n) % n
+				break
+			}
+		}
+	}
+	for k := uint64(0); k < n; k++ {
+		val := valSet.GetByIndex(uint64(perm[(start+k)%n]))
+		if canPropose(valSet, val) {
+			return val
+		}
+	}
+	return valSet.GetByIndex(uint64(perm[start]))
+}
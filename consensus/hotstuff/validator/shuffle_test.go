@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShufflePermutation(t *testing.T) {
+	for n := 0; n <= 50; n++ {
+		seed := crypto.Keccak256Hash([]byte{byte(n)})
+		perm := shuffle(seed, n)
+		assert.Equal(t, perm, shuffle(seed, n), "permutation of %d is not deterministic", n)
+
+		sorted := append([]int{}, perm...)
+		sort.Ints(sorted)
+		for i := range sorted {
+			if sorted[i] != i {
+				t.Fatalf("invalid permutation of %d: %v", n, perm)
+			}
+		}
+	}
+	assert.NotEqual(t, shuffle(common.Hash{1}, 20), shuffle(common.Hash{2}, 20))
+}
+
+func TestShuffleSelector(t *testing.T) {
+	addrs := testAddresses(7)
+	seed := crypto.Keccak256Hash([]byte("epoch"))
+	valSet := newDefaultSet(addrs, hotstuff.Shuffle)
+	valSet.SetEpochSeed(seed)
+	assert.Equal(t, seed, valSet.EpochSeed())
+
+	// following the proposer chain visits every validator once per cycle, in
+	// the order of the permutation
+	perm := shuffle(seed, len(addrs))
+	last := common.Address{}
+	for i := 0; i < 2*len(addrs); i++ {
+		valSet.CalcProposer(last, 0)
+		last = valSet.GetProposer().Address()
+		assert.Equal(t, addrs[perm[i%len(addrs)]], last)
+	}
+
+	// every node derives the same order from the same seed
+	other := newDefaultSet(addrs, hotstuff.Shuffle)
+	other.SetEpochSeed(seed)
+	for round := uint64(0); round < 10; round++ {
+		assert.Equal(t, valSet.ProposerForRound(addrs[3], round), other.ProposerForRound(addrs[3], round))
+	}
+	assert.Equal(t, seed, valSet.Copy().EpochSeed())
+	assert.Equal(t, common.Hash{}, valSet.Transition(addrs, 1).EpochSeed())
+}
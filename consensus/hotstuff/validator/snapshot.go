@@ -0,0 +1,97 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+)
+
+// ValidatorSnapshot is an immutable copy of a validator set taken at a point
+// in time. It never changes after creation, so it can be read from any
+// goroutine without locking.
+type ValidatorSnapshot struct {
+	validators hotstuff.Validators
+	indexes    map[common.Address]int
+	policy     hotstuff.SelectProposerPolicy
+	proposer   hotstuff.Validator
+	f, q       int
+	quorumSize int
+}
+
+// Snapshotter is implemented by validator sets which can take a
+// ValidatorSnapshot of themselves.
+type Snapshotter interface {
+	Snapshot() ValidatorSnapshot
+}
+
+// Snapshot returns an immutable copy of the current membership, policy and
+// proposer.
+func (valSet *defaultSet) Snapshot() ValidatorSnapshot {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	validators := make(hotstuff.Validators, len(valSet.validators))
+	copy(validators, valSet.validators)
+	return ValidatorSnapshot{
+		validators: validators,
+		// the map is replaced rather than mutated on membership change
+		indexes:  valSet.indexes,
+		policy:   valSet.policy,
+		proposer: valSet.proposer,
+		f:        valSet.f,
+		q:        valSet.q,
+		// taken from the set so that the snapshot follows its fault model
+		quorumSize: valSet.quorumSize(),
+	}
+}
+
+func (s ValidatorSnapshot) Size() int { return len(s.validators) }
+
+func (s ValidatorSnapshot) AddressList() []common.Address {
+	addrs := make([]common.Address, len(s.validators))
+	for i, v := range s.validators {
+		addrs[i] = v.Address()
+	}
+	return addrs
+}
+
+func (s ValidatorSnapshot) GetByIndex(i uint64) hotstuff.Validator {
+	if i < uint64(len(s.validators)) {
+		return s.validators[i]
+	}
+	return nil
+}
+
+func (s ValidatorSnapshot) GetByAddress(addr common.Address) (int, hotstuff.Validator) {
+	if i, ok := s.indexes[addr]; ok {
+		return i, s.validators[i]
+	}
+	return -1, nil
+}
+
+func (s ValidatorSnapshot) GetProposer() hotstuff.Validator { return s.proposer }
+
+func (s ValidatorSnapshot) Policy() hotstuff.SelectProposerPolicy { return s.policy }
+
+func (s ValidatorSnapshot) F() int { return s.f }
+
+func (s ValidatorSnapshot) Q() int { return s.q }
+
+func (s ValidatorSnapshot) QuorumSize() int { return s.quorumSize }
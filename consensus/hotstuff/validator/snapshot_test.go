@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(7), hotstuff.Sticky)
+	valSet.CalcProposer(valSet.GetByIndex(3).Address(), 1)
+
+	snap := valSet.Snapshot()
+	assert.Equal(t, valSet.AddressList(), snap.AddressList())
+	assert.Equal(t, valSet.GetProposer(), snap.GetProposer())
+	assert.Equal(t, valSet.Policy(), snap.Policy())
+	assert.Equal(t, valSet.F(), snap.F())
+	assert.Equal(t, valSet.Q(), snap.Q())
+	assert.Equal(t, valSet.QuorumSize(), snap.QuorumSize())
+	for i, v := range valSet.List() {
+		assert.Equal(t, v, snap.GetByIndex(uint64(i)))
+		idx, val := snap.GetByAddress(v.Address())
+		assert.Equal(t, i, idx)
+		assert.Equal(t, v, val)
+	}
+	assert.Nil(t, snap.GetByIndex(7))
+
+	// later changes of the set do not leak into the snapshot
+	addrs := snap.AddressList()
+	valSet.AddValidator(common.HexToAddress("0x0"))
+	valSet.RemoveValidator(addrs[3])
+	valSet.CalcProposer(addrs[0], 0)
+	assert.Equal(t, 7, snap.Size())
+	assert.Equal(t, addrs, snap.AddressList())
+	idx, _ := snap.GetByAddress(addrs[3])
+	assert.Equal(t, 3, idx)
+	idx, _ = snap.GetByAddress(common.HexToAddress("0x0"))
+	assert.Equal(t, -1, idx)
+	assert.Equal(t, 2, snap.F())
+}
+
+func TestSnapshotFaultModel(t *testing.T) {
+	addrs := testAddresses(5)
+	for _, model := range []hotstuff.FaultModel{hotstuff.BFT, hotstuff.CFT} {
+		valSet := NewSetWithFaultModel(addrs, hotstuff.RoundRobin, model)
+		snap := valSet.(Snapshotter).Snapshot()
+		assert.Equal(t, valSet.F(), snap.F(), model)
+		assert.Equal(t, valSet.Q(), snap.Q(), model)
+		assert.Equal(t, valSet.QuorumSize(), snap.QuorumSize(), model)
+	}
+	// a majority of 3 out of 5 under CFT
+	snap := NewSetWithFaultModel(addrs, hotstuff.RoundRobin, hotstuff.CFT).(Snapshotter).Snapshot()
+	assert.Equal(t, 3, snap.QuorumSize())
+}
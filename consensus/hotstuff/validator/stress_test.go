@@ -0,0 +1,147 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidatorSetStress hammers the set from many goroutines for a fixed
+// duration, it is meant to be run with -race to pin down the locking.
+func TestValidatorSetStress(t *testing.T) {
+	const (
+		workers  = 4
+		duration = 300 * time.Millisecond
+	)
+	base := testAddresses(4)
+	valSet := newDefaultSet(base, hotstuff.RoundRobin)
+	churn := make([]common.Address, 32)
+	for i := range churn {
+		churn[i] = common.BigToAddress(big.NewInt(int64(1000 + i)))
+	}
+
+	var (
+		wg       sync.WaitGroup
+		deadline = time.Now().Add(duration)
+		errs     = make(chan string, 6*workers)
+	)
+	run := func(name string, op func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errs <- fmt.Sprintf("%s panicked: %v", name, r)
+				}
+			}()
+			for i := 0; time.Now().Before(deadline); i++ {
+				op(i)
+			}
+		}()
+	}
+	for w := 0; w < workers; w++ {
+		run("AddValidator", func(i int) {
+			valSet.AddValidator(churn[i%len(churn)])
+		})
+		run("RemoveValidator", func(i int) {
+			valSet.RemoveValidator(churn[(i+7)%len(churn)])
+		})
+		run("CalcProposer", func(i int) {
+			valSet.CalcProposer(base[i%len(base)], uint64(i))
+			if proposer := valSet.GetProposer(); proposer == nil {
+				panic("nil proposer")
+			}
+		})
+		run("GetByAddress", func(i int) {
+			if _, val := valSet.GetByAddress(base[i%len(base)]); val == nil {
+				panic("base validator missing")
+			}
+		})
+		run("CheckQuorum", func(i int) {
+			// the set may grow between the two calls, so the outcome is
+			// irrelevant, only the locking is exercised
+			valSet.CheckQuorum(valSet.AddressList())
+		})
+		run("Copy", func(i int) {
+			cpy := valSet.Copy()
+			cpy.AddValidator(churn[i%len(churn)])
+			cpy.CalcProposer(base[0], uint64(i))
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// the set is still consistent
+	for i, addr := range valSet.AddressList() {
+		idx, val := valSet.GetByAddress(addr)
+		if idx != i || val == nil {
+			t.Errorf("index mismatch for %x: have %d, want %d", addr, idx, i)
+		}
+	}
+}
+
+func TestAtomicSize(t *testing.T) {
+	base := testAddresses(4)
+	valSet := newDefaultSet(base, hotstuff.RoundRobin)
+	churn := make([]common.Address, 16)
+	for i := range churn {
+		churn[i] = common.BigToAddress(big.NewInt(int64(1000 + i)))
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				addr := churn[(i+w)%len(churn)]
+				if !valSet.AddValidator(addr) {
+					valSet.RemoveValidator(addr)
+				}
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 2000; i++ {
+			if size := valSet.Size(); size < len(base) || size > len(base)+len(churn) {
+				t.Errorf("size out of bounds: %d", size)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	assert.Equal(t, len(valSet.List()), valSet.Size())
+	valSet.RemoveValidators(churn)
+	assert.Equal(t, len(base), valSet.Size())
+	assert.Equal(t, len(base), valSet.Copy().Size())
+}
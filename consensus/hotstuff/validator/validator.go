@@ -19,13 +19,33 @@
 package validator
 
 import (
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/hotstuff"
 )
 
 func New(addr common.Address) hotstuff.Validator {
+	return NewWithWeight(addr, 1)
+}
+
+// NewWithWeight creates a validator carrying the given voting power.
+func NewWithWeight(addr common.Address, weight uint64) hotstuff.Validator {
 	return &defaultValidator{
-		address: addr,
+		address:  addr,
+		weight:   weight,
+		eligible: true,
+	}
+}
+
+// NewWithBLS creates a validator carrying the BLS public key used to verify
+// aggregated signatures, a nil key is allowed for legacy validators.
+func NewWithBLS(addr common.Address, pubKey []byte) hotstuff.Validator {
+	return &defaultValidator{
+		address:   addr,
+		weight:    1,
+		blsPubKey: common.CopyBytes(pubKey),
+		eligible:  true,
 	}
 }
 
@@ -33,6 +53,148 @@ func NewSet(addrs []common.Address, policy hotstuff.SelectProposerPolicy) hotstu
 	return newDefaultSet(addrs, policy)
 }
 
+// NewSetFrom creates an independent validator set holding the validators,
+// with their weights and keys, and the policy of src. Only the membership is
+// carried over, the new set uses the default order and selector.
+func NewSetFrom(src hotstuff.ValidatorSet) hotstuff.ValidatorSet {
+	list := src.List()
+	validators := make([]hotstuff.Validator, len(list))
+	for i, val := range list {
+		validators[i] = cloneValidator(val)
+	}
+	return newDefaultSetWithValidators(validators, src.Policy())
+}
+
+// NewSetWithSelector creates a validator set which picks proposers with the
+// given selector instead of the one implied by policy, see SetSelector.
+func NewSetWithSelector(addrs []common.Address, policy hotstuff.SelectProposerPolicy, selector hotstuff.ProposalSelector) hotstuff.ValidatorSet {
+	valSet := newDefaultSet(addrs, policy)
+	valSet.SetSelector(selector)
+	return valSet
+}
+
+// NewSetOrdered creates a validator set ordered by less instead of the default
+// ascending address order. Round robin and sticky selection depend on the
+// order, so less must be a deterministic strict weak ordering shared by all
+// nodes. A nil less falls back to the ascending order.
+func NewSetOrdered(addrs []common.Address, policy hotstuff.SelectProposerPolicy, less func(a, b common.Address) bool) hotstuff.ValidatorSet {
+	validators := make([]hotstuff.Validator, len(addrs))
+	for i, addr := range addrs {
+		validators[i] = New(addr)
+	}
+	if less == nil {
+		return newOrderedDefaultSet(validators, policy, nil)
+	}
+	return newOrderedDefaultSet(validators, policy, func(a, b hotstuff.Validator) bool {
+		return less(a.Address(), b.Address())
+	})
+}
+
+// NewSetUnsorted creates a validator set keeping addrs in the given order,
+// e.g. the on-chain order, instead of sorting them. Added validators are
+// appended. Selectors and indexes operate on that order, so for consensus
+// safety all nodes must supply the very same order.
+func NewSetUnsorted(addrs []common.Address, policy hotstuff.SelectProposerPolicy) hotstuff.ValidatorSet {
+	validators := make([]hotstuff.Validator, len(addrs))
+	for i, addr := range addrs {
+		validators[i] = New(addr)
+	}
+	return newUnsortedDefaultSet(validators, policy)
+}
+
+// NewSetWithLimit creates a validator set which AddValidator and AddValidators
+// refuse to grow beyond maxSize validators, a maxSize of zero means no limit.
+// It fails with ErrSetFull if addrs already holds more validators.
+func NewSetWithLimit(addrs []common.Address, policy hotstuff.SelectProposerPolicy, maxSize int) (hotstuff.ValidatorSet, error) {
+	valSet := newDefaultSet(addrs, policy)
+	if maxSize > 0 && valSet.Size() > maxSize {
+		return nil, fmt.Errorf("%w: have %d validators, want at most %d", ErrSetFull, valSet.Size(), maxSize)
+	}
+	valSet.maxSize = maxSize
+	return valSet, nil
+}
+
+// NewSetWithFaultModel creates a validator set whose F, Q and quorum checks
+// follow the given fault model, NewSet uses BFT.
+func NewSetWithFaultModel(addrs []common.Address, policy hotstuff.SelectProposerPolicy, model hotstuff.FaultModel) hotstuff.ValidatorSet {
+	validators := make([]hotstuff.Validator, len(addrs))
+	for i, addr := range addrs {
+		validators[i] = New(addr)
+	}
+	return initDefaultSet(&defaultSet{faultModel: model}, validators, policy)
+}
+
+// ByWeight orders validators by descending weight, validators of equal weight
+// are ordered by address.
+func ByWeight(a, b hotstuff.Validator) bool {
+	return a.Weight() > b.Weight()
+}
+
+// NewSetSafe creates a validator set like NewSet, but rejects an empty address
+// list with ErrEmptySet, the zero address with ErrZeroAddress and a duplicated
+// one with ErrInvalidParticipant. NewSet silently drops the zero address.
+func NewSetSafe(addrs []common.Address, policy hotstuff.SelectProposerPolicy) (hotstuff.ValidatorSet, error) {
+	if len(addrs) == 0 {
+		return nil, ErrEmptySet
+	}
+	seen := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		if emptyAddress(addr) {
+			return nil, ErrZeroAddress
+		}
+		if _, ok := seen[addr]; ok {
+			return nil, ErrInvalidParticipant
+		}
+		seen[addr] = struct{}{}
+	}
+	return newDefaultSet(addrs, policy), nil
+}
+
+// NewBLSSet creates a validator set where pubKeys[i] is the BLS public key of
+// addrs[i], nil for legacy validators. Both slices must have the same length.
+func NewBLSSet(addrs []common.Address, pubKeys [][]byte, policy hotstuff.SelectProposerPolicy) (hotstuff.ValidatorSet, error) {
+	if len(addrs) != len(pubKeys) {
+		return nil, ErrInvalidParticipant
+	}
+	validators := make([]hotstuff.Validator, len(addrs))
+	for i, addr := range addrs {
+		if emptyAddress(addr) {
+			return nil, ErrZeroAddress
+		}
+		validators[i] = NewWithBLS(addr, pubKeys[i])
+	}
+	return newDefaultSetWithValidators(validators, policy), nil
+}
+
+// NewWeightedSet creates a validator set where weights[i] is the voting power
+// of addrs[i]. Both slices must have the same length and every weight must be
+// positive, and their sum must fit in a uint64.
+func NewWeightedSet(addrs []common.Address, weights []uint64, policy hotstuff.SelectProposerPolicy) (hotstuff.ValidatorSet, error) {
+	return NewWeightedSetOrdered(addrs, weights, policy, nil)
+}
+
+// NewWeightedSetOrdered creates a weighted validator set ordered by less, e.g.
+// ByWeight. Validators less considers equal are ordered by address.
+func NewWeightedSetOrdered(addrs []common.Address, weights []uint64, policy hotstuff.SelectProposerPolicy, less func(a, b hotstuff.Validator) bool) (hotstuff.ValidatorSet, error) {
+	if len(addrs) != len(weights) {
+		return nil, ErrInvalidParticipant
+	}
+	validators := make([]hotstuff.Validator, len(addrs))
+	for i, addr := range addrs {
+		if weights[i] == 0 {
+			return nil, ErrInvalidParticipant
+		}
+		if emptyAddress(addr) {
+			return nil, ErrZeroAddress
+		}
+		validators[i] = NewWithWeight(addr, weights[i])
+	}
+	if err := checkTotalWeight(validators); err != nil {
+		return nil, err
+	}
+	return newOrderedDefaultSet(validators, policy, less), nil
+}
+
 func ExtractValidators(extraData []byte) []common.Address {
 	// get the validator addresses
 	addrs := make([]common.Address, (len(extraData) / common.AddressLength))
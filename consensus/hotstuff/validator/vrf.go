@@ -0,0 +1,167 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrVRFNotConfigured is returned when a VRF proof is supplied to a set
+	// without any VRF implementation installed.
+	ErrVRFNotConfigured = errors.New("vrf not configured")
+
+	// ErrInvalidVRFProof is returned when the VRF implementation rejects a proof.
+	ErrInvalidVRFProof = errors.New("invalid vrf proof")
+
+	// ErrVRFSelection is returned by CalcProposerCtx when the VRF selector
+	// fails to pick a member of the set.
+	ErrVRFSelection = errors.New("vrf proposer selection failed")
+
+	// ErrNotSelected is returned by VerifyProposer if the claimed proposer is
+	// not the one selected for the round.
+	ErrNotSelected = errors.New("not the selected proposer")
+
+	// ErrNoPublicKey is returned by VerifyProposer if the claimed proposer has
+	// no public key to check its proof against.
+	ErrNoPublicKey = errors.New("validator has no public key")
+)
+
+// VRF is the pluggable verifiable random function used by the VRF proposer
+// policy. Integrators supply their own implementation so that the curve and
+// proof format are not fixed by this package.
+type VRF interface {
+	// Prove computes the VRF output over seed with the local private key and
+	// returns it together with a proof of correctness.
+	Prove(seed []byte) (output, proof []byte, err error)
+
+	// Verify checks proof over seed against the prover's public key and
+	// returns the VRF output on success.
+	Verify(pubKey, seed, proof []byte) (output []byte, err error)
+}
+
+// VRFOutputReader is implemented by validator sets which carry the VRF output
+// of the previous block.
+type VRFOutputReader interface {
+	VRFOutput() []byte
+}
+
+// SetVRF installs the VRF implementation used to verify proposer proofs.
+func (valSet *defaultSet) SetVRF(vrf VRF) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+	valSet.vrf = vrf
+}
+
+// VRFOutput returns the VRF output of the previous block, or nil if none has
+// been applied yet.
+func (valSet *defaultSet) VRFOutput() []byte {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return common.CopyBytes(valSet.vrfOutput)
+}
+
+// VRFSeed returns the seed which the next proposer should prove over, it is
+// derived from the VRF output currently held by the set.
+func (valSet *defaultSet) VRFSeed() []byte {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+	return crypto.Keccak256(valSet.vrfOutput)
+}
+
+// UpdateVRFOutput verifies the VRF proof published by a proposer over the
+// current VRF seed and, if valid, installs its output as the source of
+// randomness for subsequent proposer selection.
+func (valSet *defaultSet) UpdateVRFOutput(pubKey, proof []byte) error {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	if valSet.vrf == nil {
+		return ErrVRFNotConfigured
+	}
+	output, err := valSet.vrf.Verify(pubKey, crypto.Keccak256(valSet.vrfOutput), proof)
+	if err != nil || len(output) == 0 {
+		return ErrInvalidVRFProof
+	}
+	valSet.vrfOutput = common.CopyBytes(output)
+	return nil
+}
+
+// VerifyProposer checks the leader claim of a proposed block: addr must be the
+// proposer which the set selects for round after the stored last proposer,
+// and proof must be its VRF proof over the current VRF seed, checked against
+// the public key of the validator. Full nodes call it before accepting the
+// block, the output is then installed with UpdateVRFOutput.
+func (valSet *defaultSet) VerifyProposer(addr common.Address, round uint64, proof []byte) error {
+	valSet.validatorMu.RLock()
+	view := valSet.detach()
+	lastProposer := valSet.lastProposer
+	valSet.validatorMu.RUnlock()
+
+	if view.vrf == nil {
+		return ErrVRFNotConfigured
+	}
+	_, val := view.GetByAddress(addr)
+	if val == nil {
+		return fmt.Errorf("%w: %s", ErrNonMember, addr.Hex())
+	}
+	if proposer := view.selectProposer(lastProposer, round); proposer == nil || proposer.Address() != addr {
+		return fmt.Errorf("%w: %s at round %d", ErrNotSelected, addr.Hex(), round)
+	}
+	pubKey := val.BLSPublicKey()
+	if len(pubKey) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoPublicKey, addr.Hex())
+	}
+	output, err := view.vrf.Verify(pubKey, crypto.Keccak256(view.vrfOutput), proof)
+	if err != nil || len(output) == 0 {
+		return ErrInvalidVRFProof
+	}
+	return nil
+}
+
+// vrfSeed mixes the previous VRF output, the last proposer and the round into
+// a 32 bytes seed. Every node derives the same seed given the same inputs.
+func vrfSeed(output []byte, proposer common.Address, round uint64) common.Hash {
+	var enc [8]byte
+	binary.BigEndian.PutUint64(enc[:], round)
+	return crypto.Keccak256Hash(output, proposer.Bytes(), enc[:])
+}
+
+// vrfSelector picks the validator at the seed modulo the size, or the next
+// one which may propose.
+func vrfSelector(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+	size := valSet.Size()
+	if size == 0 {
+		return nil
+	}
+	var output []byte
+	if reader, ok := valSet.(VRFOutputReader); ok {
+		output = reader.VRFOutput()
+	}
+	seed := vrfSeed(output, proposer, round)
+	pick := new(big.Int).Mod(seed.Big(), big.NewInt(int64(size)))
+	return nextActive(valSet, pick.Uint64())
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (C) 2021 The Zion Authors
+ * This file is part of The Zion library.
+ *
+ * The Zion is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The Zion is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The Zion.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package validator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/hotstuff"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockVRF "proves" by hashing the seed with the key, the proof is the output
+// itself. It is only meant to exercise the plumbing.
+type mockVRF struct {
+	key []byte
+}
+
+func (m *mockVRF) Prove(seed []byte) ([]byte, []byte, error) {
+	out := crypto.Keccak256(m.key, seed)
+	return out, out, nil
+}
+
+func (m *mockVRF) Verify(pubKey, seed, proof []byte) ([]byte, error) {
+	if !bytes.Equal(crypto.Keccak256(pubKey, seed), proof) {
+		return nil, errors.New("bad proof")
+	}
+	return proof, nil
+}
+
+func testAddresses(n int) []common.Address {
+	addrs := make([]common.Address, n)
+	for i := 0; i < n; i++ {
+		addrs[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+	}
+	return addrs
+}
+
+func TestVRFSelector(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(7), hotstuff.VRF)
+	last := valSet.GetByIndex(3).Address()
+
+	picked := make(map[common.Address]int)
+	for round := uint64(0); round < 700; round++ {
+		valSet.CalcProposer(last, round)
+		proposer := valSet.GetProposer()
+		if proposer == nil {
+			t.Fatalf("nil proposer at round %d", round)
+		}
+		picked[proposer.Address()]++
+
+		// every node must derive the same proposer from the same inputs
+		other := newDefaultSet(valSet.AddressList(), hotstuff.VRF)
+		other.CalcProposer(last, round)
+		assert.Equal(t, proposer.Address(), other.GetProposer().Address())
+	}
+	assert.Equal(t, 7, len(picked), "every validator should be picked at least once")
+
+	valSet.CalcProposer(common.Address{}, 0)
+	assert.NotNil(t, valSet.GetProposer())
+
+	empty := newDefaultSet(nil, hotstuff.VRF)
+	empty.CalcProposer(last, 1)
+	assert.Nil(t, empty.GetProposer())
+}
+
+func TestUpdateVRFOutput(t *testing.T) {
+	valSet := newDefaultSet(testAddresses(4), hotstuff.VRF)
+	key := []byte("proposer key")
+
+	assert.Equal(t, ErrVRFNotConfigured, valSet.UpdateVRFOutput(key, nil))
+
+	valSet.SetVRF(&mockVRF{key: key})
+	assert.Equal(t, ErrInvalidVRFProof, valSet.UpdateVRFOutput(key, []byte("garbage")))
+	assert.Nil(t, valSet.VRFOutput())
+
+	prover := &mockVRF{key: key}
+	output, proof, _ := prover.Prove(valSet.VRFSeed())
+	assert.NoError(t, valSet.UpdateVRFOutput(key, proof))
+	assert.Equal(t, output, valSet.VRFOutput())
+
+	// the output is carried over by Copy so that copies select identically
+	cpy := valSet.Copy()
+	for round := uint64(0); round < 20; round++ {
+		valSet.CalcProposer(common.Address{}, round)
+		cpy.CalcProposer(common.Address{}, round)
+		assert.Equal(t, valSet.GetProposer(), cpy.GetProposer())
+	}
+}
+
+func TestCalcProposerCtx(t *testing.T) {
+	addrs := testAddresses(4)
+
+	// non VRF policies take the fast path
+	valSet := newDefaultSet(addrs, hotstuff.RoundRobin)
+	assert.NoError(t, valSet.CalcProposerCtx(context.Background(), addrs[0], 0))
+	assert.Equal(t, addrs[1], valSet.GetProposer().Address())
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, valSet.CalcProposerCtx(cancelled, addrs[0], 1))
+
+	vrfSet := newDefaultSet(addrs, hotstuff.VRF)
+	assert.NoError(t, vrfSet.CalcProposerCtx(context.Background(), addrs[0], 3))
+	want := vrfSet.ProposerForRound(addrs[0], 3)
+	assert.Equal(t, want, vrfSet.GetProposer())
+
+	// a slow selector is abandoned once the context expires
+	release := make(chan struct{})
+	defer close(release)
+	vrfSet.SetSelector(func(valSet hotstuff.ValidatorSet, proposer common.Address, round uint64) hotstuff.Validator {
+		<-release
+		return valSet.GetByIndex(0)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, vrfSet.CalcProposerCtx(ctx, addrs[0], 4))
+	assert.Equal(t, want, vrfSet.GetProposer())
+
+	// a failing selector is reported rather than papered over
+	vrfSet.SetSelector(func(hotstuff.ValidatorSet, common.Address, uint64) hotstuff.Validator {
+		return New(common.HexToAddress("0xdead"))
+	})
+	assert.Equal(t, ErrVRFSelection, vrfSet.CalcProposerCtx(context.Background(), addrs[0], 5))
+	assert.Equal(t, want, vrfSet.GetProposer())
+
+	// the genesis proposer is honoured as by CalcProposer
+	genesisSet := newDefaultSet(addrs, hotstuff.VRF)
+	genesisSet.SetGenesisProposer(addrs[3])
+	for round := uint64(0); round < 8; round++ {
+		assert.NoError(t, genesisSet.CalcProposerCtx(context.Background(), common.Address{}, round))
+		assert.Equal(t, genesisSet.ProposerForRound(common.Address{}, round), genesisSet.GetProposer())
+	}
+	assert.NoError(t, genesisSet.CalcProposerCtx(context.Background(), common.Address{}, 0))
+	assert.Equal(t, addrs[3], genesisSet.GetProposer().Address())
+}
+
+func TestVerifyProposer(t *testing.T) {
+	addrs := testAddresses(4)
+	keys := make([][]byte, len(addrs))
+	for i := range keys {
+		keys[i] = []byte{byte(i + 1)}
+	}
+	set, err := NewBLSSet(addrs, keys, hotstuff.VRF)
+	if err != nil {
+		t.Fatalf("failed to create set: %v", err)
+	}
+	valSet := set.(*defaultSet)
+	valSet.SetLastProposer(addrs[2])
+	round := uint64(5)
+
+	idx, proposer := valSet.GetByAddress(valSet.ProposerForRound(addrs[2], round).Address())
+	_, proof, _ := (&mockVRF{key: keys[idx]}).Prove(valSet.VRFSeed())
+
+	assert.Equal(t, ErrVRFNotConfigured, valSet.VerifyProposer(proposer.Address(), round, proof))
+	valSet.SetVRF(&mockVRF{})
+	assert.NoError(t, valSet.VerifyProposer(proposer.Address(), round, proof))
+
+	// a forged proof, or one by another validator, is rejected
+	assert.Equal(t, ErrInvalidVRFProof, valSet.VerifyProposer(proposer.Address(), round, []byte("forged")))
+	other := addrs[(idx+1)%len(addrs)]
+	_, otherProof, _ := (&mockVRF{key: keys[(idx+1)%len(addrs)]}).Prove(valSet.VRFSeed())
+	if err := valSet.VerifyProposer(other, round, otherProof); !errors.Is(err, ErrNotSelected) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNotSelected)
+	}
+	if err := valSet.VerifyProposer(common.HexToAddress("0x100"), round, proof); !errors.Is(err, ErrNonMember) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNonMember)
+	}
+
+	// the selection depends on the round
+	for r := round + 1; ; r++ {
+		if valSet.ProposerForRound(addrs[2], r).Address() != proposer.Address() {
+			assert.ErrorIs(t, valSet.VerifyProposer(proposer.Address(), r, proof), ErrNotSelected)
+			break
+		}
+	}
+
+	// legacy validators without a key can not prove their selection
+	legacy := newDefaultSet(addrs, hotstuff.VRF)
+	legacy.SetVRF(&mockVRF{})
+	legacy.SetLastProposer(addrs[2])
+	assert.ErrorIs(t, legacy.VerifyProposer(proposer.Address(), round, proof), ErrNoPublicKey)
+}